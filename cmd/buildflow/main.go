@@ -10,14 +10,22 @@ import (
 )
 
 func main() {
-	if err := core(); err != nil {
+	ctx, cancel := signal.WithCancel(context.Background(), os.Stderr)
+	err := core(ctx)
+	cancel()
+	if sig := signal.Received(ctx); sig != nil {
+		// the build is interrupted by the signal
+		if err != nil {
+			logrus.Error(err)
+		}
+		os.Exit(signal.ExitCode(sig))
+	}
+	if err != nil {
 		logrus.Fatal(err)
 	}
 }
 
-func core() error {
+func core(ctx context.Context) error {
 	runner := cli.Runner{}
-	ctx, cancel := context.WithCancel(context.Background())
-	go signal.Handle(os.Stderr, cancel)
 	return runner.Run(ctx, os.Args...)
 }
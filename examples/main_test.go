@@ -40,6 +40,14 @@ func TestBuildflow(t *testing.T) { //nolint:funlen
 			title: "read_file",
 			file:  "read_file.yaml",
 		},
+		{
+			title: "read_file reads a directory",
+			file:  "read_dir.yaml",
+		},
+		{
+			title: "find files by glob",
+			file:  "glob.yaml",
+		},
 		{
 			title: "write_file",
 			file:  "write_file.yaml",
@@ -60,6 +68,45 @@ func TestBuildflow(t *testing.T) { //nolint:funlen
 			title: "dynamic task by items",
 			file:  "dynamic_task.yaml",
 		},
+		{
+			title: "run another configuration file",
+			file:  "buildflow.yaml",
+		},
+		{
+			title: "the circular build fails",
+			file:  "buildflow_circular.yaml",
+			exp: icmd.Expected{
+				ExitCode: 1,
+			},
+		},
+		{
+			title: "tengo script task",
+			file:  "script.yaml",
+		},
+		{
+			title: "retry a task",
+			file:  "retry.yaml",
+		},
+		{
+			title: "allow the task failure",
+			file:  "allow_failure.yaml",
+		},
+		{
+			title: "skip dependents when a dependency fails",
+			file:  "if.yaml",
+		},
+		{
+			title: "run independent phases in parallel",
+			file:  "phase_dag.yaml",
+		},
+		{
+			title: "resource pools",
+			file:  "resource.yaml",
+		},
+		{
+			title: "parallelism of the phase and weight of the task",
+			file:  "parallelism.yaml",
+		},
 		{
 			title: "skip a phase",
 			file:  "skip_phase.yaml",
@@ -71,6 +118,44 @@ func TestBuildflow(t *testing.T) { //nolint:funlen
 				ExitCode: 1,
 			},
 		},
+		{
+			title: "fail_fast cancels running tasks",
+			file:  "fail_fast.yaml",
+			exp: icmd.Expected{
+				ExitCode: 1,
+			},
+		},
+		{
+			title: "if: failure tasks are run even if fail_fast cancels the phase",
+			file:  "fail_fast_if_failure.yaml",
+		},
+		{
+			title: "the phase timeout cancels running tasks",
+			file:  "timeout.yaml",
+			exp: icmd.Expected{
+				ExitCode: 1,
+			},
+		},
+		{
+			title: "dir and defaults.shell",
+			file:  "dir.yaml",
+		},
+		{
+			title: "the task cache",
+			file:  "cache.yaml",
+		},
+		{
+			title: "matrix",
+			file:  "matrix.yaml",
+		},
+		{
+			title: "expand the task by outputs of the dependency",
+			file:  "lazy_expand.yaml",
+		},
+		{
+			title: "finally phases and tasks",
+			file:  "finally.yaml",
+		},
 		{
 			title: "if there are unknown fields in configuration file, buildflow run fails",
 			file:  "unknown_field.yaml",
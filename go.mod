@@ -7,12 +7,15 @@ require (
 	github.com/Masterminds/semver v1.5.0 // indirect
 	github.com/Masterminds/sprig v2.22.0+incompatible
 	github.com/Songmu/timeout v0.4.0
+	github.com/bmatcuk/doublestar v1.3.4
 	github.com/d5/tengo/v2 v2.7.0
 	github.com/google/go-github/v32 v32.1.0
 	github.com/google/uuid v1.1.2 // indirect
 	github.com/huandu/xstrings v1.3.2 // indirect
 	github.com/imdario/mergo v0.3.11 // indirect
+	github.com/joho/godotenv v1.3.0
 	github.com/mitchellh/copystructure v1.0.0 // indirect
+	github.com/pelletier/go-toml v1.8.1
 	github.com/sirupsen/logrus v1.8.1
 	github.com/stretchr/testify v1.7.0
 	github.com/suzuki-shunsuke/go-ci-env v1.1.0
@@ -22,5 +25,6 @@ require (
 	github.com/suzuki-shunsuke/go-findconfig v1.0.0
 	github.com/urfave/cli/v2 v2.3.0
 	golang.org/x/oauth2 v0.0.0-20200902213428-5d25da1a8d43
+	gopkg.in/ini.v1 v1.62.0
 	gopkg.in/yaml.v2 v2.4.0
 )
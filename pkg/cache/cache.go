@@ -0,0 +1,264 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/suzuki-shunsuke/buildflow/pkg/domain"
+)
+
+const (
+	entryFile  = "entry.json"
+	outputsDir = "outputs"
+	// the prefix of the temporal directory where the entry is created
+	tmpPrefix = ".tmp-"
+)
+
+// Cache stores the results and outputs of tasks in the local directory.
+// Each entry is stored in the directory whose name is the cache key.
+type Cache struct {
+	Dir string
+	// MaxSize is the maximum total size of entries in bytes. 0 means there is no limitation.
+	MaxSize int64
+	// MaxAge is the maximum age of entries. 0 means there is no limitation.
+	MaxAge time.Duration
+	Now    func() time.Time
+}
+
+// Entry is the result of the task which succeeded.
+type Entry struct {
+	Result domain.CommandResult
+	// Files is the list of relative paths of outputs
+	Files []string
+}
+
+func New(dir string, maxSize int64, maxAge time.Duration) Cache {
+	return Cache{
+		Dir:     dir,
+		MaxSize: maxSize,
+		MaxAge:  maxAge,
+		Now:     time.Now,
+	}
+}
+
+// Key returns the cache key which is the hash of inputs and the contents of files.
+// The paths of files are the relative paths from the root directory.
+func Key(inputs interface{}, root string, files []string) (string, error) {
+	hash := sha256.New()
+	if err := json.NewEncoder(hash).Encode(inputs); err != nil {
+		return "", fmt.Errorf("failed to encode the cache key inputs: %w", err)
+	}
+	sorted := make([]string, len(files))
+	copy(sorted, files)
+	sort.Strings(sorted)
+	for _, p := range sorted {
+		fmt.Fprintf(hash, "%s\x00", p)
+		if err := hashFile(hash, filepath.Join(root, p)); err != nil {
+			return "", err
+		}
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func hashFile(w io.Writer, p string) error {
+	f, err := os.Open(p)
+	if err != nil {
+		return fmt.Errorf("failed to open a source file: %w", err)
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return fmt.Errorf("failed to read a source file: %w", err)
+	}
+	_, err = w.Write(h.Sum(nil))
+	return err
+}
+
+func (cache Cache) expired(now, lastUsed time.Time) bool {
+	return cache.MaxAge > 0 && now.Sub(lastUsed) > cache.MaxAge
+}
+
+func (cache Cache) entryDir(key string) string {
+	return filepath.Join(cache.Dir, key)
+}
+
+// Get returns the entry of the key.
+// If the entry isn't found or isn't used in MaxAge, false is returned.
+func (cache Cache) Get(key string) (Entry, bool, error) {
+	entry := Entry{}
+	p := filepath.Join(cache.entryDir(key), entryFile)
+	// the modification time of the entry file is the last used time
+	stat, err := os.Stat(p)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return entry, false, nil
+		}
+		return entry, false, fmt.Errorf("failed to read the cache entry: %w", err)
+	}
+	now := cache.Now()
+	if cache.expired(now, stat.ModTime()) {
+		return entry, false, nil
+	}
+	b, err := ioutil.ReadFile(p)
+	if err != nil {
+		return entry, false, fmt.Errorf("failed to read the cache entry: %w", err)
+	}
+	if err := json.Unmarshal(b, &entry); err != nil {
+		return entry, false, fmt.Errorf("failed to parse the cache entry: %w", err)
+	}
+	if err := os.Chtimes(p, now, now); err != nil {
+		return entry, false, fmt.Errorf("failed to update the last used time of the cache entry: %w", err)
+	}
+	return entry, true, nil
+}
+
+// Restore copies outputs of the entry to the directory.
+func (cache Cache) Restore(key, dir string, entry Entry) error {
+	src := filepath.Join(cache.entryDir(key), outputsDir)
+	for _, p := range entry.Files {
+		if err := copyFile(filepath.Join(src, p), filepath.Join(dir, p)); err != nil {
+			return fmt.Errorf("failed to restore the output %s: %w", p, err)
+		}
+	}
+	return nil
+}
+
+// Put stores the entry and outputs of the entry in the directory.
+// The entry is created in the temporal directory and renamed, so the broken entry isn't referred.
+func (cache Cache) Put(key, dir string, entry Entry) error {
+	if err := os.MkdirAll(cache.Dir, 0o755); err != nil { //nolint:gomnd
+		return fmt.Errorf("failed to create the cache directory: %w", err)
+	}
+	tmp, err := ioutil.TempDir(cache.Dir, tmpPrefix)
+	if err != nil {
+		return fmt.Errorf("failed to create a temporal directory: %w", err)
+	}
+	defer os.RemoveAll(tmp)
+	for _, p := range entry.Files {
+		if err := copyFile(filepath.Join(dir, p), filepath.Join(tmp, outputsDir, p)); err != nil {
+			return fmt.Errorf("failed to store the output %s: %w", p, err)
+		}
+	}
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to encode the cache entry: %w", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(tmp, entryFile), b, 0o644); err != nil { //nolint:gomnd
+		return fmt.Errorf("failed to write the cache entry: %w", err)
+	}
+	dest := cache.entryDir(key)
+	if err := os.RemoveAll(dest); err != nil {
+		return fmt.Errorf("failed to remove the old cache entry: %w", err)
+	}
+	if err := os.Rename(tmp, dest); err != nil {
+		return fmt.Errorf("failed to store the cache entry: %w", err)
+	}
+	return nil
+}
+
+func copyFile(src, dest string) error {
+	stat, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil { //nolint:gomnd
+		return err
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, stat.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+type entryInfo struct {
+	path     string
+	size     int64
+	lastUsed time.Time
+}
+
+// Evict removes expired entries and removes least recently used entries until the total size is less than MaxSize.
+func (cache Cache) Evict() error {
+	infos, err := ioutil.ReadDir(cache.Dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("failed to read the cache directory: %w", err)
+	}
+	now := cache.Now()
+	entries := make([]entryInfo, 0, len(infos))
+	var total int64
+	for _, info := range infos {
+		if !info.IsDir() || strings.HasPrefix(info.Name(), tmpPrefix) {
+			continue
+		}
+		p := filepath.Join(cache.Dir, info.Name())
+		stat, err := os.Stat(filepath.Join(p, entryFile))
+		if err != nil || cache.expired(now, stat.ModTime()) {
+			// the broken entry and the entry which isn't used recently are removed
+			if err := os.RemoveAll(p); err != nil {
+				return fmt.Errorf("failed to remove the cache entry: %w", err)
+			}
+			continue
+		}
+		size, err := dirSize(p)
+		if err != nil {
+			return err
+		}
+		total += size
+		entries = append(entries, entryInfo{path: p, size: size, lastUsed: stat.ModTime()})
+	}
+	if cache.MaxSize <= 0 {
+		return nil
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].lastUsed.Before(entries[j].lastUsed)
+	})
+	for _, entry := range entries {
+		if total <= cache.MaxSize {
+			return nil
+		}
+		if err := os.RemoveAll(entry.path); err != nil {
+			return fmt.Errorf("failed to remove the cache entry: %w", err)
+		}
+		total -= entry.size
+	}
+	return nil
+}
+
+func dirSize(dir string) (int64, error) {
+	var size int64
+	err := filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			size += info.Size()
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to get the size of the cache entry: %w", err)
+	}
+	return size, nil
+}
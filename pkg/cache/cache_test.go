@@ -0,0 +1,136 @@
+package cache_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/suzuki-shunsuke/buildflow/pkg/cache"
+	"github.com/suzuki-shunsuke/buildflow/pkg/domain"
+)
+
+func writeFile(t *testing.T, p, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(p, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestKey(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "foo.txt"), "foo")
+	writeFile(t, filepath.Join(dir, "bar.txt"), "bar")
+
+	key1, err := cache.Key("echo foo", dir, []string{"foo.txt", "bar.txt"})
+	if !assert.Nil(t, err) {
+		return
+	}
+	// the order of files doesn't change the key
+	key2, err := cache.Key("echo foo", dir, []string{"bar.txt", "foo.txt"})
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, key1, key2)
+
+	// the key is changed if the content of the source is changed
+	writeFile(t, filepath.Join(dir, "foo.txt"), "zoo")
+	key3, err := cache.Key("echo foo", dir, []string{"foo.txt", "bar.txt"})
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.NotEqual(t, key1, key3)
+
+	// the key is changed if the inputs are changed
+	key4, err := cache.Key("echo bar", dir, []string{"foo.txt", "bar.txt"})
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.NotEqual(t, key3, key4)
+
+	_, err = cache.Key("echo foo", dir, []string{"not_found.txt"})
+	assert.NotNil(t, err)
+}
+
+func TestCache(t *testing.T) {
+	dir := t.TempDir()
+	c := cache.New(filepath.Join(dir, "cache"), 0, time.Hour)
+	wd := filepath.Join(dir, "wd")
+	writeFile(t, filepath.Join(wd, "dist", "foo.txt"), "foo")
+
+	_, found, err := c.Get("key")
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.False(t, found)
+
+	entry := cache.Entry{
+		Result: domain.CommandResult{
+			Stdout: "hello",
+		},
+		Files: []string{"dist/foo.txt"},
+	}
+	if err := c.Put("key", wd, entry); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.RemoveAll(filepath.Join(wd, "dist")); err != nil {
+		t.Fatal(err)
+	}
+
+	e, found, err := c.Get("key")
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.True(t, found)
+	assert.Equal(t, entry, e)
+	if err := c.Restore("key", wd, e); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(filepath.Join(wd, "dist", "foo.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "foo", string(b))
+
+	// the entry which isn't used in MaxAge is expired
+	c.Now = func() time.Time {
+		return time.Now().Add(2 * time.Hour)
+	}
+	_, found, err = c.Get("key")
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.False(t, found)
+	if err := c.Evict(); err != nil {
+		t.Fatal(err)
+	}
+	_, err = os.Stat(filepath.Join(dir, "cache", "key"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestCache_Evict(t *testing.T) {
+	dir := t.TempDir()
+	c := cache.New(dir, 10, 0)
+	wd := t.TempDir()
+	writeFile(t, filepath.Join(wd, "foo.txt"), "0123456789")
+	for _, key := range []string{"old", "new"} {
+		if err := c.Put(key, wd, cache.Entry{Files: []string{"foo.txt"}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	old := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(filepath.Join(dir, "old", "entry.json"), old, old); err != nil {
+		t.Fatal(err)
+	}
+	// the total size exceeds MaxSize, so the least recently used entry is removed
+	if err := c.Evict(); err != nil {
+		t.Fatal(err)
+	}
+	_, err := os.Stat(filepath.Join(dir, "old"))
+	assert.True(t, os.IsNotExist(err))
+}
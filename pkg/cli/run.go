@@ -1,19 +1,22 @@
 package cli
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
 	"time"
 
 	"github.com/sirupsen/logrus"
+	"github.com/suzuki-shunsuke/buildflow/pkg/cache"
 	"github.com/suzuki-shunsuke/buildflow/pkg/config"
 	"github.com/suzuki-shunsuke/buildflow/pkg/controller"
 	"github.com/suzuki-shunsuke/buildflow/pkg/execute"
 	"github.com/suzuki-shunsuke/buildflow/pkg/file"
+	"github.com/suzuki-shunsuke/buildflow/pkg/git"
 	"github.com/suzuki-shunsuke/buildflow/pkg/github"
+	"github.com/suzuki-shunsuke/buildflow/pkg/httpclient"
 	"github.com/suzuki-shunsuke/go-findconfig/findconfig"
 	"github.com/urfave/cli/v2"
-	"gopkg.in/yaml.v2"
 )
 
 func (runner Runner) setCLIArg(c *cli.Context, cfg config.Config) config.Config {
@@ -29,86 +32,30 @@ func (runner Runner) setCLIArg(c *cli.Context, cfg config.Config) config.Config
 	if logLevel := c.String("log-level"); logLevel != "" {
 		cfg.LogLevel = logLevel
 	}
+	if c.Bool("no-cache") {
+		cfg.Cache.Disabled = true
+	}
 	return cfg
 }
 
-func (runner Runner) importPhaseConfig(cfgPhases []config.Phase, wd string) ([]config.Phase, error) { //nolint:dupl
-	phases := []config.Phase{}
-	for _, phase := range cfgPhases {
-		if phase.Import == "" {
-			phases = append(phases, phase)
-			continue
-		}
-		p := phase.Import
-		if !filepath.IsAbs(p) {
-			p = filepath.Join(wd, p)
-		}
-		arr, err := func() ([]config.Phase, error) {
-			arr := []config.Phase{}
-			file, err := os.Open(p)
-			if err != nil {
-				return nil, err
-			}
-			defer file.Close()
-			if err := yaml.NewDecoder(file).Decode(&arr); err != nil {
-				return nil, err
-			}
-			return arr, nil
-		}()
-		if err != nil {
-			return phases, err
-		}
-		phases = append(phases, arr...)
+// newCache creates the task cache.
+// The relative path of the cache directory is treated as the relative path from the directory where the configuration file exists.
+func newCache(cfg config.Cache, wd string) (controller.Cache, error) {
+	if cfg.Disabled {
+		return nil, nil
 	}
-	return phases, nil
-}
-
-func (runner Runner) importTaskConfig(cfgTasks []config.Task, wd string) ([]config.Task, error) { //nolint:dupl
-	tasks := []config.Task{}
-	for _, task := range cfgTasks {
-		if task.Import == "" {
-			tasks = append(tasks, task)
-			continue
-		}
-		p := task.Import
-		if !filepath.IsAbs(p) {
-			p = filepath.Join(wd, p)
-		}
-		arr, err := func() ([]config.Task, error) {
-			arr := []config.Task{}
-			file, err := os.Open(p)
-			if err != nil {
-				return nil, err
-			}
-			defer file.Close()
-			if err := yaml.NewDecoder(file).Decode(&arr); err != nil {
-				return nil, err
-			}
-			return arr, nil
-		}()
+	dir := cfg.Dir
+	if dir == "" {
+		d, err := os.UserCacheDir()
 		if err != nil {
-			return nil, err
+			return nil, fmt.Errorf("failed to get the user's cache directory: %w", err)
 		}
-		tasks = append(tasks, arr...)
-	}
-	return tasks, nil
-}
-
-func (runner Runner) importConfig(cfg config.Config, wd string) (config.Config, error) {
-	phases, err := runner.importPhaseConfig(cfg.Phases, wd)
-	if err != nil {
-		return cfg, err
+		dir = filepath.Join(d, "buildflow")
 	}
-	for i, phase := range phases {
-		tasks, err := runner.importTaskConfig(phase.Tasks, wd)
-		if err != nil {
-			return cfg, err
-		}
-		phase.Tasks = tasks
-		phases[i] = phase
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(wd, dir)
 	}
-	cfg.Phases = phases
-	return cfg, nil
+	return cache.New(dir, int64(*cfg.MaxSize), *cfg.MaxAge), nil
 }
 
 func (runner Runner) action(c *cli.Context) error {
@@ -123,8 +70,11 @@ func (runner Runner) action(c *cli.Context) error {
 	if err != nil {
 		return err
 	}
+	if !filepath.IsAbs(cfgPath) {
+		cfgPath = filepath.Join(wd, cfgPath)
+	}
 
-	if c, err := runner.importConfig(cfg, wd); err != nil {
+	if c, err := reader.Import(cfg, wd); err != nil {
 		return err
 	} else {
 		cfg = c
@@ -156,6 +106,11 @@ func (runner Runner) action(c *cli.Context) error {
 		"log_level": cfg.LogLevel,
 	}).Debug("config")
 
+	taskCache, err := newCache(cfg.Cache, filepath.Dir(cfgPath))
+	if err != nil {
+		return err
+	}
+
 	ctrl := controller.Controller{
 		Config:     cfg,
 		GitHub:     ghClient,
@@ -165,6 +120,13 @@ func (runner Runner) action(c *cli.Context) error {
 		Timer:      timer{},
 		FileReader: file.Reader{},
 		FileWriter: file.Writer{},
+		HTTPClient: httpclient.New(),
+		Git:        git.Client{},
+		Cache:      taskCache,
+		ConfigReader: config.Reader{
+			ExistFile: findconfig.Exist,
+		},
+		ConfigPaths: []string{cfgPath},
 	}
 
 	return ctrl.Run(c.Context, filepath.Dir(cfgPath))
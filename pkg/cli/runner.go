@@ -47,6 +47,10 @@ func (runner Runner) Run(ctx context.Context, args ...string) error {
 						Aliases: []string{"c"},
 						Usage:   "configuration file path",
 					},
+					&cli.BoolFlag{
+						Name:  "no-cache",
+						Usage: "run tasks without the task cache",
+					},
 				},
 			},
 			{
@@ -0,0 +1,106 @@
+package config
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Cache is the setting of the local cache directory where the results and outputs of tasks are stored.
+type Cache struct {
+	// Dir is the cache directory. The default is the buildflow directory in the user's cache directory.
+	Dir string
+	// MaxSize and MaxAge are pointers to distinguish 0, which means there is no limitation, from the unset value
+	MaxSize *ByteSize      `yaml:"max_size"`
+	MaxAge  *time.Duration `yaml:"max_age"`
+	// Disabled is set by the command line option --no-cache
+	Disabled bool `yaml:"-"`
+}
+
+const (
+	// DefaultCacheMaxSize is 1 GiB
+	DefaultCacheMaxSize ByteSize = 1 << 30
+	// DefaultCacheMaxAge is 1 week
+	DefaultCacheMaxAge = 7 * 24 * time.Hour
+)
+
+// TaskCache is the setting of the task cache.
+// The cache key is computed from the rendered command, the contents of sources, keys, and the values of environment variables.
+// If the successful result of the same key is stored, the task isn't run and the outputs are restored.
+type TaskCache struct {
+	// Sources are glob patterns of the files which the task depends on
+	Sources []Template
+	// Outputs are glob patterns of the files which the task generates
+	Outputs []Template
+	// Keys are additional inputs of the cache key
+	Keys []Template
+	// Env is the names of environment variables which are added to the cache key
+	Env []string
+}
+
+// Enabled returns true if the task cache is configured.
+func (cache TaskCache) Enabled() bool {
+	return len(cache.Sources) != 0 || len(cache.Outputs) != 0 || len(cache.Keys) != 0 || len(cache.Env) != 0
+}
+
+// ByteSize is the size in bytes.
+// The value should be either an integer or a string with the unit B, KB, MB, GB, or TB.
+// The unit is 1024-based.
+type ByteSize int64
+
+var byteUnits = []struct { //nolint:gochecknoglobals
+	suffix string
+	size   int64
+}{
+	{"TB", 1 << 40},
+	{"GB", 1 << 30},
+	{"MB", 1 << 20},
+	{"KB", 1 << 10},
+	{"B", 1},
+}
+
+func parseByteSize(s string) (ByteSize, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	for _, unit := range byteUnits {
+		if !strings.HasSuffix(s, unit.suffix) {
+			continue
+		}
+		n, err := strconv.ParseInt(strings.TrimSpace(strings.TrimSuffix(s, unit.suffix)), 10, 64)
+		if err != nil || n < 0 {
+			return 0, errors.New("invalid size: " + s)
+		}
+		return ByteSize(n * unit.size), nil
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n < 0 {
+		return 0, errors.New("invalid size: " + s)
+	}
+	return ByteSize(n), nil
+}
+
+func (size *ByteSize) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var val interface{}
+	if err := unmarshal(&val); err != nil {
+		return err
+	}
+	switch v := val.(type) {
+	case int:
+		if v < 0 {
+			return errors.New("size must be greater than or equal to 0: " + strconv.Itoa(v))
+		}
+		*size = ByteSize(v)
+		return nil
+	case string:
+		s, err := parseByteSize(v)
+		if err != nil {
+			return err
+		}
+		*size = s
+		return nil
+	case nil:
+		return nil
+	default:
+		return errors.New("size must be either an integer or a string such as 500MB")
+	}
+}
@@ -14,11 +14,12 @@ type Command struct {
 	Env         Envs
 }
 
-func (cmd Command) SetDefault() Command {
+// SetDefault sets the shell and shell options of defaults if the shell isn't set.
+func (cmd Command) SetDefault(defaults Defaults) Command {
 	if cmd.Shell == "" {
-		cmd.Shell = "/bin/sh"
+		cmd.Shell = defaults.Shell
 		if cmd.ShellOpts == nil {
-			cmd.ShellOpts = []string{"-c"}
+			cmd.ShellOpts = defaults.ShellOpts
 		}
 	}
 	return cmd
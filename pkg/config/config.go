@@ -4,7 +4,10 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"strings"
+	"time"
 
+	"github.com/suzuki-shunsuke/buildflow/pkg/execute"
 	"github.com/suzuki-shunsuke/buildflow/pkg/expr"
 	"github.com/suzuki-shunsuke/go-ci-env/cienv"
 	"github.com/suzuki-shunsuke/go-convmap/convmap"
@@ -16,6 +19,22 @@ type Phase struct {
 	Condition PhaseCondition
 	Meta      map[string]interface{}
 	Import    string
+	// FailFast is the flag whether running tasks are cancelled when a task fails.
+	// If this isn't set, the build's fail_fast is used.
+	FailFast *bool `yaml:"fail_fast"`
+	// Parallelism is the maximum number of tasks which are run in parallel in the phase.
+	Parallelism Parallelism
+	// Timeout is the timeout of the phase. 0 means there is no timeout.
+	Timeout time.Duration
+	// DependsOn is the list of phase names which this phase depends on.
+	DependsOn []string `yaml:"depends_on"`
+	// Defaults overrides the build's defaults in the phase.
+	Defaults Defaults
+	// Finally is the list of tasks which are run after the other tasks of the phase are finished,
+	// even if the phase failed or was cancelled.
+	Finally []Task
+	// IsFinally is true if the phase is the build's finally phase
+	IsFinally bool `yaml:"-"`
 }
 
 type PhaseCondition struct {
@@ -37,9 +56,55 @@ type Config struct {
 	LogLevel    string `yaml:"log_level"`
 	GitHubToken string `yaml:"github_token"`
 	Env         Env    `yaml:"-"`
-	Parallelism int
+	Parallelism Parallelism
 	PR          bool
+	Git         Git
 	Meta        map[string]interface{}
+	FailFast    bool `yaml:"fail_fast"`
+	// Resources is the capacity of named resource pools
+	Resources map[string]int
+	// Timeout is the timeout of the build. 0 means there is no timeout.
+	Timeout  time.Duration
+	Defaults Defaults
+	Cache    Cache
+	// Finally is the list of phases which are run sequentially after the other phases are finished,
+	// even if the build failed, exited, or was cancelled.
+	Finally []Phase
+}
+
+// Defaults is the default values of tasks.
+type Defaults struct {
+	// Timeout is the default timeout of tasks
+	Timeout execute.Timeout
+	// Shell and ShellOpts are the default shell and shell options of command tasks
+	Shell     string
+	ShellOpts []string `yaml:"shell_options"`
+}
+
+// merge returns the defaults whose unset fields are set by parent.
+func (defaults Defaults) merge(parent Defaults) Defaults {
+	if defaults.Timeout.Duration == 0 {
+		defaults.Timeout.Duration = parent.Timeout.Duration
+	}
+	if defaults.Timeout.KillAfter == 0 {
+		defaults.Timeout.KillAfter = parent.Timeout.KillAfter
+	}
+	if defaults.Shell == "" {
+		defaults.Shell = parent.Shell
+		if defaults.ShellOpts == nil {
+			defaults.ShellOpts = parent.ShellOpts
+		}
+	}
+	return defaults
+}
+
+// DefaultTaskTimeout is the default timeout of tasks if defaults.timeout isn't set.
+const DefaultTaskTimeout = time.Hour
+
+type Git struct {
+	ChangedFiles bool   `yaml:"changed_files"`
+	BaseBranch   string `yaml:"base_branch"`
+	Staged       bool
 }
 
 type Env struct {
@@ -66,12 +131,35 @@ func convertMeta(meta map[string]interface{}) error {
 	return nil
 }
 
+// validateResources validates resources which the task uses.
+func validateResources(pools map[string]int, task Task) error {
+	for name, n := range task.Resources {
+		capacity, ok := pools[name]
+		if !ok {
+			return errors.New("the resource pool isn't found: " + name)
+		}
+		if n <= 0 {
+			return fmt.Errorf("the number of units of the resource pool %s must be greater than 0: %d", name, n)
+		}
+		if n > capacity {
+			return fmt.Errorf("the number of units of the resource pool %s exceeds the capacity %d: %d", name, capacity, n)
+		}
+	}
+	return nil
+}
+
 func Set(cfg Config) (Config, error) {
+	cfg, err := mergeFinally(cfg)
+	if err != nil {
+		return cfg, err
+	}
 	cfg = setDefault(setEnv(cfg))
 	if err := convertMeta(cfg.Meta); err != nil {
 		return cfg, fmt.Errorf(".meta is invalid: %w", err)
 	}
 	phaseNames := make(map[string]struct{}, len(cfg.Phases))
+	// problems of the task dependencies are reported together
+	dependencyErrors := []string{}
 	for i, phase := range cfg.Phases {
 		if _, ok := phaseNames[phase.Name]; ok {
 			return cfg, errors.New("phase name is duplicated: " + phase.Name)
@@ -84,14 +172,39 @@ func Set(cfg Config) (Config, error) {
 			if err := task.Set(); err != nil {
 				return cfg, fmt.Errorf("task is invalid: %w", err)
 			}
+			if err := validateResources(cfg.Resources, task); err != nil {
+				return cfg, fmt.Errorf("task is invalid: %s: %w", task.Name.Text, err)
+			}
 			phase.Tasks[j] = task
 		}
+		dependencyErrors = append(dependencyErrors, validateDependencies(phase)...)
 		cfg.Phases[i] = phase
 	}
+	dependencyErrors = append(dependencyErrors, validatePhaseDependencies(cfg.Phases)...)
+	if len(dependencyErrors) != 0 {
+		return cfg, errors.New("the task dependency is invalid:\n" + strings.Join(dependencyErrors, "\n"))
+	}
 	return cfg, nil
 }
 
 func setDefault(cfg Config) Config {
+	if cfg.Defaults.Timeout.Duration == 0 {
+		cfg.Defaults.Timeout.Duration = DefaultTaskTimeout
+	}
+	if cfg.Cache.MaxSize == nil {
+		maxSize := DefaultCacheMaxSize
+		cfg.Cache.MaxSize = &maxSize
+	}
+	if cfg.Cache.MaxAge == nil {
+		maxAge := DefaultCacheMaxAge
+		cfg.Cache.MaxAge = &maxAge
+	}
+	if cfg.Defaults.Shell == "" {
+		cfg.Defaults.Shell = "/bin/sh"
+		if cfg.Defaults.ShellOpts == nil {
+			cfg.Defaults.ShellOpts = []string{"-c"}
+		}
+	}
 	if !cfg.Condition.Fail.Initialized {
 		b, err := expr.NewBool(`
 result := false
@@ -113,6 +226,10 @@ for phase in Phases {
 		phase.Condition.Skip.SetDefaultBool(false)
 		phase.Condition.Exit.SetDefaultBool(false)
 		phase.Condition.Fail.SetDefaultBool(false)
+		if phase.FailFast == nil {
+			failFast := cfg.FailFast
+			phase.FailFast = &failFast
+		}
 
 		if !phase.Condition.Fail.Initialized {
 			b, err := expr.NewBool(`
@@ -130,11 +247,19 @@ for task in Tasks {
 			phase.Condition.Fail.Fixed = false
 		}
 
+		phase.Defaults = phase.Defaults.merge(cfg.Defaults)
 		for j, task := range phase.Tasks {
 			if task.Command.Command.Text != "" || task.Command.CommandFile != "" {
-				task.Command = task.Command.SetDefault()
+				task.Command = task.Command.SetDefault(phase.Defaults)
 			}
 			task.When.SetDefaultBool(true)
+			task.AllowFailure.SetDefaultBool(false)
+			if task.Timeout.Duration == 0 {
+				task.Timeout.Duration = phase.Defaults.Timeout.Duration
+			}
+			if task.Timeout.KillAfter == 0 {
+				task.Timeout.KillAfter = phase.Defaults.Timeout.KillAfter
+			}
 			phase.Tasks[j] = task
 		}
 		cfg.Phases[i] = phase
@@ -0,0 +1,164 @@
+package config_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/suzuki-shunsuke/buildflow/pkg/config"
+	"gopkg.in/yaml.v2"
+)
+
+func TestSet_defaults(t *testing.T) {
+	data := []struct {
+		title     string
+		cfg       string
+		shell     string
+		shellOpts []string
+		timeout   time.Duration
+	}{
+		{
+			title: "default",
+			cfg: `
+phases:
+- name: main
+  tasks:
+  - name: foo
+    command:
+      command: echo foo
+`,
+			shell:     "/bin/sh",
+			shellOpts: []string{"-c"},
+			timeout:   time.Hour,
+		},
+		{
+			title: "build defaults",
+			cfg: `
+defaults:
+  shell: bash
+  shell_options:
+  - -euo
+  - pipefail
+  - -c
+  timeout:
+    duration: 10m
+phases:
+- name: main
+  tasks:
+  - name: foo
+    command:
+      command: echo foo
+`,
+			shell:     "bash",
+			shellOpts: []string{"-euo", "pipefail", "-c"},
+			timeout:   10 * time.Minute,
+		},
+		{
+			title: "phase defaults override build defaults",
+			cfg: `
+defaults:
+  shell: bash
+  shell_options:
+  - -c
+  timeout:
+    duration: 10m
+phases:
+- name: main
+  defaults:
+    shell: zsh
+  tasks:
+  - name: foo
+    command:
+      command: echo foo
+`,
+			shell:   "zsh",
+			timeout: 10 * time.Minute,
+		},
+		{
+			title: "task's shell",
+			cfg: `
+defaults:
+  shell: bash
+  shell_options:
+  - -c
+phases:
+- name: main
+  tasks:
+  - name: foo
+    command:
+      shell: node
+      command: console.log("foo")
+`,
+			shell:   "node",
+			timeout: time.Hour,
+		},
+	}
+	for _, d := range data {
+		d := d
+		t.Run(d.title, func(t *testing.T) {
+			cfg := config.Config{}
+			if err := yaml.UnmarshalStrict([]byte(d.cfg), &cfg); err != nil {
+				t.Fatal(err)
+			}
+			cfg, err := config.Set(cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			task := cfg.Phases[0].Tasks[0]
+			assert.Equal(t, d.shell, task.Command.Shell)
+			assert.Equal(t, d.shellOpts, task.Command.ShellOpts)
+			assert.Equal(t, d.timeout, task.Timeout.Duration)
+		})
+	}
+}
+
+func TestSet_cache(t *testing.T) {
+	data := []struct {
+		title   string
+		cfg     string
+		maxSize config.ByteSize
+		maxAge  time.Duration
+	}{
+		{
+			title:   "default",
+			cfg:     `phases: []`,
+			maxSize: config.DefaultCacheMaxSize,
+			maxAge:  config.DefaultCacheMaxAge,
+		},
+		{
+			title: "0 means there is no limitation",
+			cfg: `
+cache:
+  max_size: 0
+  max_age: 0
+phases: []
+`,
+		},
+		{
+			title: "set",
+			cfg: `
+cache:
+  max_size: 500MB
+  max_age: 24h
+phases: []
+`,
+			maxSize: 500 << 20,
+			maxAge:  24 * time.Hour,
+		},
+	}
+	for _, d := range data {
+		d := d
+		t.Run(d.title, func(t *testing.T) {
+			cfg := config.Config{}
+			if err := yaml.UnmarshalStrict([]byte(d.cfg), &cfg); err != nil {
+				t.Fatal(err)
+			}
+			cfg, err := config.Set(cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, d.maxSize, *cfg.Cache.MaxSize)
+			assert.Equal(t, d.maxAge, *cfg.Cache.MaxAge)
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package config
+
+import (
+	"github.com/suzuki-shunsuke/buildflow/pkg/expr"
+	"github.com/suzuki-shunsuke/go-convmap/convmap"
+)
+
+type Data struct {
+	Data    interface{}
+	Program expr.Program
+}
+
+func (data Data) Run(params map[string]interface{}) (interface{}, error) {
+	if data.Data != nil {
+		return data.Data, nil
+	}
+	return data.Program.Run(params)
+}
+
+func (data *Data) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var src interface{}
+	if err := unmarshal(&src); err != nil {
+		return err
+	}
+	switch t := src.(type) {
+	case string:
+		prog, err := expr.New(t)
+		if err != nil {
+			return err
+		}
+		data.Program = prog
+		return nil
+	default:
+		if t == nil {
+			return nil
+		}
+		a, err := convmap.Convert(t)
+		if err != nil {
+			return err
+		}
+		data.Data = a
+		return nil
+	}
+}
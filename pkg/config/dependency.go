@@ -2,6 +2,8 @@ package config
 
 import (
 	"fmt"
+	"reflect"
+	"strings"
 
 	"github.com/suzuki-shunsuke/buildflow/pkg/expr"
 )
@@ -45,3 +47,270 @@ func (dependency *Dependency) UnmarshalYAML(unmarshal func(interface{}) error) e
 		return fmt.Errorf("the value should be a tengo script or a list of dependencies: %v", val)
 	}
 }
+
+// staticNames returns the names of tasks which the task is expanded into.
+// If the names can't be determined before the build, ok is false.
+func (task Task) staticNames() (names []string, ok bool) {
+	isTemplate := strings.Contains(task.Name.Text, "{{")
+	if task.Items.Items == nil || !task.Matrix.Empty() {
+		if isTemplate {
+			return nil, false
+		}
+		return []string{task.Name.Text}, true
+	}
+	items := []Item{}
+	value := reflect.ValueOf(task.Items.Items)
+	switch value.Kind() { //nolint:exhaustive
+	case reflect.Slice:
+		for i := 0; i < value.Len(); i++ {
+			items = append(items, Item{Key: i, Value: value.Index(i).Interface()})
+		}
+	case reflect.Map:
+		for _, key := range value.MapKeys() {
+			items = append(items, Item{Key: key.Interface(), Value: value.MapIndex(key).Interface()})
+		}
+	default:
+		return nil, false
+	}
+	names = make([]string, len(items))
+	for i, item := range items {
+		if !isTemplate {
+			names[i] = task.Name.Text
+			continue
+		}
+		// only .Item can be referred before the build
+		name, err := task.Name.Template.Render(map[string]interface{}{
+			"Item": map[string]interface{}{
+				"Key":   item.Key,
+				"Value": item.Value,
+			},
+		})
+		if err != nil || strings.Contains(name, "<no value>") {
+			return nil, false
+		}
+		names[i] = name
+	}
+	return names, true
+}
+
+// namePattern is the static prefix and suffix of the task name which is determined during the build.
+type namePattern struct {
+	prefix string
+	suffix string
+}
+
+func newNamePattern(text string) namePattern {
+	pattern := namePattern{prefix: text}
+	idx := strings.Index(text, "{{")
+	if idx == -1 {
+		return pattern
+	}
+	pattern.prefix = text[:idx]
+	if strings.HasPrefix(text[idx:], "{{- ") {
+		pattern.prefix = strings.TrimRight(pattern.prefix, " \t\r\n")
+	}
+	idx = strings.LastIndex(text, "}}")
+	pattern.suffix = text[idx+2:]
+	if strings.HasSuffix(text[:idx], " -") {
+		pattern.suffix = strings.TrimLeft(pattern.suffix, " \t\r\n")
+	}
+	return pattern
+}
+
+// Match returns true if the name may be rendered from the pattern.
+func (pattern namePattern) Match(name string) bool {
+	return len(name) >= len(pattern.prefix)+len(pattern.suffix) &&
+		strings.HasPrefix(name, pattern.prefix) && strings.HasSuffix(name, pattern.suffix)
+}
+
+func matchNamePatterns(patterns []namePattern, name string) bool {
+	for _, pattern := range patterns {
+		if pattern.Match(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// validateDependencies validates the static dependencies of tasks in the phase.
+// Unknown names, self dependencies, cycles, and ambiguous names are reported.
+// Tasks whose names are determined during the build are ignored,
+// and unknown names which may match them are not reported.
+func validateDependencies(phase Phase) []string {
+	msgs := []string{}
+	// the task name -> indices of tasks
+	nameMap := map[string][]int{}
+	// the patterns of names which are determined during the build
+	patterns := []namePattern{}
+	for i, task := range phase.Tasks {
+		names, ok := task.staticNames()
+		if !ok {
+			patterns = append(patterns, newNamePattern(task.Name.Text))
+			continue
+		}
+		if (task.Items.Items == nil && !task.Items.Program.Empty()) || !task.Matrix.Empty() {
+			// the number of tasks is determined during the build
+			nameMap[task.Name.Text] = append(nameMap[task.Name.Text], i)
+			continue
+		}
+		for _, name := range names {
+			// tasks expanded from the same entry with the same name are a fan-in, not ambiguous
+			if indices := nameMap[name]; len(indices) != 0 && indices[len(indices)-1] == i {
+				continue
+			}
+			nameMap[name] = append(nameMap[name], i)
+		}
+	}
+
+	edges := make([][]int, len(phase.Tasks))
+	for i, task := range phase.Tasks {
+		for _, name := range task.Dependency.Names {
+			indices, ok := nameMap[name]
+			if !ok {
+				if !matchNamePatterns(patterns, name) {
+					msgs = append(msgs, fmt.Sprintf("phase %s: task %s: the dependency isn't found: %s", phase.Name, task.Name.Text, name))
+				}
+				continue
+			}
+			if len(indices) > 1 {
+				msgs = append(msgs, fmt.Sprintf("phase %s: task %s: the dependency is ambiguous because %d tasks have the name: %s", phase.Name, task.Name.Text, len(indices), name))
+			}
+			for _, idx := range indices {
+				if idx == i {
+					msgs = append(msgs, fmt.Sprintf("phase %s: task %s: the task depends on itself", phase.Name, task.Name.Text))
+					continue
+				}
+				if phase.Tasks[idx].IsFinally && !task.IsFinally {
+					msgs = append(msgs, fmt.Sprintf("phase %s: task %s: the task can't depend on the finally task: %s", phase.Name, task.Name.Text, name))
+					continue
+				}
+				edges[i] = append(edges[i], idx)
+			}
+		}
+	}
+
+	for _, cycle := range findCycles(edges) {
+		names := make([]string, len(cycle))
+		for i, idx := range cycle {
+			names[i] = phase.Tasks[idx].Name.Text
+		}
+		msgs = append(msgs, fmt.Sprintf("phase %s: the dependency is circular: %s", phase.Name, strings.Join(names, " -> ")))
+	}
+	return msgs
+}
+
+// PhaseDependencies returns the indices of phases which each phase depends on.
+// If no phase has depends_on, each phase depends on the previous phase so that phases are run sequentially.
+// Unknown phase names are ignored.
+func PhaseDependencies(phases []Phase) [][]int {
+	deps := make([][]int, len(phases))
+	isDAG := false
+	for _, phase := range phases {
+		if len(phase.DependsOn) != 0 {
+			isDAG = true
+			break
+		}
+	}
+	if !isDAG {
+		for i := 1; i < len(phases); i++ {
+			deps[i] = []int{i - 1}
+		}
+		return deps
+	}
+	indices := make(map[string]int, len(phases))
+	for i, phase := range phases {
+		indices[phase.Name] = i
+	}
+	for i, phase := range phases {
+		for _, name := range phase.DependsOn {
+			if idx, ok := indices[name]; ok {
+				deps[i] = append(deps[i], idx)
+			}
+		}
+	}
+	return deps
+}
+
+// validatePhaseDependencies validates depends_on of phases.
+// Unknown names, self dependencies, and cycles are reported.
+// Finally phases can't be depended on.
+func validatePhaseDependencies(phases []Phase) []string {
+	msgs := []string{}
+	names := make(map[string]struct{}, len(phases))
+	for _, phase := range phases {
+		if phase.IsFinally {
+			continue
+		}
+		names[phase.Name] = struct{}{}
+	}
+	for _, phase := range phases {
+		for _, name := range phase.DependsOn {
+			if name == phase.Name {
+				msgs = append(msgs, fmt.Sprintf("phase %s: the phase depends on itself", phase.Name))
+				continue
+			}
+			if _, ok := names[name]; !ok {
+				msgs = append(msgs, fmt.Sprintf("phase %s: the phase in depends_on isn't found: %s", phase.Name, name))
+			}
+		}
+	}
+	deps := PhaseDependencies(phases)
+	for i, phase := range phases {
+		// self dependencies are reported above
+		edges := []int{}
+		for _, idx := range deps[i] {
+			if phases[idx].Name != phase.Name {
+				edges = append(edges, idx)
+			}
+		}
+		deps[i] = edges
+	}
+	for _, cycle := range findCycles(deps) {
+		names := make([]string, len(cycle))
+		for i, idx := range cycle {
+			names[i] = phases[idx].Name
+		}
+		msgs = append(msgs, "the phase dependency is circular: "+strings.Join(names, " -> "))
+	}
+	return msgs
+}
+
+// findCycles finds cycles of the graph by depth first search.
+// Each cycle is returned as the path whose first and last nodes are same.
+func findCycles(edges [][]int) [][]int {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	states := make([]int, len(edges))
+	stack := []int{}
+	cycles := [][]int{}
+	var visit func(node int)
+	visit = func(node int) {
+		states[node] = visiting
+		stack = append(stack, node)
+		for _, next := range edges[node] {
+			switch states[next] {
+			case unvisited:
+				visit(next)
+			case visiting:
+				for i, n := range stack {
+					if n == next {
+						cycle := append([]int{}, stack[i:]...)
+						cycles = append(cycles, append(cycle, next))
+						break
+					}
+				}
+			}
+		}
+		stack = stack[:len(stack)-1]
+		states[node] = visited
+	}
+	for node := range edges {
+		if states[node] == unvisited {
+			visit(node)
+		}
+	}
+	return cycles
+}
@@ -0,0 +1,238 @@
+package config_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/suzuki-shunsuke/buildflow/pkg/config"
+	"gopkg.in/yaml.v2"
+)
+
+func TestSet_dependency(t *testing.T) {
+	data := []struct {
+		title  string
+		cfg    string
+		isErr  bool
+		errMsg []string
+	}{
+		{
+			title: "valid",
+			cfg: `
+phases:
+- name: main
+  tasks:
+  - name: foo
+    command:
+      command: echo foo
+  - name: "bar {{.Item.Value}}"
+    command:
+      command: echo bar
+    items:
+    - a
+    - b
+  - name: zoo
+    command:
+      command: echo zoo
+    dependency:
+    - foo
+    - bar a
+`,
+		},
+		{
+			title: "tasks expanded from the same entry have the same name",
+			cfg: `
+phases:
+- name: main
+  tasks:
+  - name: foo
+    command:
+      command: echo foo
+    items:
+    - a
+    - b
+  - name: zoo
+    command:
+      command: echo zoo
+    dependency:
+    - foo
+`,
+		},
+		{
+			title: "the task name is determined during the build",
+			cfg: `
+phases:
+- name: main
+  tasks:
+  - name: "{{.Meta.name}}"
+    command:
+      command: echo foo
+  - name: zoo
+    command:
+      command: echo zoo
+    dependency:
+    - foo
+`,
+		},
+		{
+			title: "unknown names which can't match the names determined during the build",
+			cfg: `
+phases:
+- name: main
+  tasks:
+  - name: "build {{.Meta.name}}"
+    command:
+      command: echo foo
+  - name: "{{- .Meta.name }} test"
+    command:
+      command: echo test
+  - name: zoo
+    command:
+      command: echo zoo
+    dependency:
+    - build foo
+    - foo test
+    - biuld foo
+`,
+			isErr:  true,
+			errMsg: []string{"phase main: task zoo: the dependency isn't found: biuld foo"},
+		},
+		{
+			title: "phase dependency",
+			cfg: `
+phases:
+- name: foo
+  tasks: []
+  depends_on:
+  - bar
+- name: bar
+  tasks: []
+  depends_on:
+  - foo
+- name: zoo
+  tasks: []
+  depends_on:
+  - zoo
+  - unknown
+`,
+			isErr: true,
+			errMsg: []string{
+				"phase zoo: the phase depends on itself",
+				"phase zoo: the phase in depends_on isn't found: unknown",
+				"the phase dependency is circular: foo -> bar -> foo",
+			},
+		},
+		{
+			title: "all problems are reported",
+			cfg: `
+phases:
+- name: main
+  tasks:
+  - name: foo
+    command:
+      command: echo foo
+    dependency:
+    - foo
+  - name: bar
+    command:
+      command: echo bar
+    dependency:
+    - baz
+  - name: baz
+    command:
+      command: echo baz
+    dependency:
+    - yoo
+  - name: yoo
+    command:
+      command: echo yoo
+    dependency:
+    - bar
+- name: second
+  tasks:
+  - name: item
+    command:
+      command: echo a
+  - name: item
+    command:
+      command: echo b
+  - name: zoo
+    command:
+      command: echo zoo
+    dependency:
+    - item
+    - unknown
+`,
+			isErr: true,
+			errMsg: []string{
+				"phase main: task foo: the task depends on itself",
+				"phase main: the dependency is circular: bar -> baz -> yoo -> bar",
+				"phase second: task zoo: the dependency is ambiguous because 2 tasks have the name: item",
+				"phase second: task zoo: the dependency isn't found: unknown",
+			},
+		},
+		{
+			title: "finally phases and tasks",
+			cfg: `
+phases:
+- name: main
+  tasks:
+  - name: foo
+    command:
+      command: echo foo
+    dependency:
+    - cleanup
+  finally:
+  - name: cleanup
+    command:
+      command: echo cleanup
+    dependency:
+    - foo
+- name: second
+  tasks: []
+  depends_on:
+  - teardown
+finally:
+- name: teardown
+  tasks: []
+`,
+			isErr: true,
+			errMsg: []string{
+				"phase main: task foo: the task can't depend on the finally task: cleanup",
+				"phase second: the phase in depends_on isn't found: teardown",
+			},
+		},
+		{
+			title: "the finally phase can't exit the build",
+			cfg: `
+phases: []
+finally:
+- name: teardown
+  tasks: []
+  condition:
+    exit: true
+`,
+			isErr:  true,
+			errMsg: []string{"the finally phase can't have condition.exit: teardown"},
+		},
+	}
+	for _, d := range data {
+		d := d
+		t.Run(d.title, func(t *testing.T) {
+			cfg := config.Config{}
+			if err := yaml.UnmarshalStrict([]byte(d.cfg), &cfg); err != nil {
+				t.Fatal(err)
+			}
+			_, err := config.Set(cfg)
+			if !d.isErr {
+				assert.Nil(t, err)
+				return
+			}
+			if !assert.NotNil(t, err) {
+				return
+			}
+			for _, msg := range d.errMsg {
+				assert.Contains(t, err.Error(), msg)
+			}
+		})
+	}
+}
@@ -0,0 +1,31 @@
+package config
+
+import (
+	"errors"
+)
+
+// mergeFinally moves finally phases and tasks to phases and tasks with the flag IsFinally,
+// so that they are validated and their default values are set in the same way as other phases and tasks.
+func mergeFinally(cfg Config) (Config, error) {
+	for _, phase := range cfg.Finally {
+		if len(phase.DependsOn) != 0 {
+			return cfg, errors.New("the finally phase can't have depends_on: " + phase.Name)
+		}
+		// all finally phases are run, so the finally phase can't exit the build
+		if phase.Condition.Exit.Initialized {
+			return cfg, errors.New("the finally phase can't have condition.exit: " + phase.Name)
+		}
+		phase.IsFinally = true
+		cfg.Phases = append(cfg.Phases, phase)
+	}
+	cfg.Finally = nil
+	for i, phase := range cfg.Phases {
+		for _, task := range phase.Finally {
+			task.IsFinally = true
+			phase.Tasks = append(phase.Tasks, task)
+		}
+		phase.Finally = nil
+		cfg.Phases[i] = phase
+	}
+	return cfg, nil
+}
@@ -0,0 +1,107 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v2"
+)
+
+func (reader Reader) importPhaseConfig(cfgPhases []Phase, wd string) ([]Phase, error) { //nolint:dupl
+	phases := []Phase{}
+	for _, phase := range cfgPhases {
+		if phase.Import == "" {
+			phases = append(phases, phase)
+			continue
+		}
+		p := phase.Import
+		if !filepath.IsAbs(p) {
+			p = filepath.Join(wd, p)
+		}
+		arr, err := func() ([]Phase, error) {
+			arr := []Phase{}
+			file, err := os.Open(p)
+			if err != nil {
+				return nil, err
+			}
+			defer file.Close()
+			if err := yaml.NewDecoder(file).Decode(&arr); err != nil {
+				return nil, err
+			}
+			return arr, nil
+		}()
+		if err != nil {
+			return phases, err
+		}
+		phases = append(phases, arr...)
+	}
+	return phases, nil
+}
+
+func (reader Reader) importTaskConfig(cfgTasks []Task, wd string) ([]Task, error) { //nolint:dupl
+	tasks := []Task{}
+	for _, task := range cfgTasks {
+		if task.Import == "" {
+			tasks = append(tasks, task)
+			continue
+		}
+		p := task.Import
+		if !filepath.IsAbs(p) {
+			p = filepath.Join(wd, p)
+		}
+		arr, err := func() ([]Task, error) {
+			arr := []Task{}
+			file, err := os.Open(p)
+			if err != nil {
+				return nil, err
+			}
+			defer file.Close()
+			if err := yaml.NewDecoder(file).Decode(&arr); err != nil {
+				return nil, err
+			}
+			return arr, nil
+		}()
+		if err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, arr...)
+	}
+	return tasks, nil
+}
+
+// Import reads phases and tasks from files which are specified by phase.import and task.import.
+// Relative paths are treated as the relative path from wd.
+func (reader Reader) Import(cfg Config, wd string) (Config, error) {
+	phases, err := reader.importPhases(cfg.Phases, wd)
+	if err != nil {
+		return cfg, err
+	}
+	cfg.Phases = phases
+	finally, err := reader.importPhases(cfg.Finally, wd)
+	if err != nil {
+		return cfg, err
+	}
+	cfg.Finally = finally
+	return cfg, nil
+}
+
+func (reader Reader) importPhases(cfgPhases []Phase, wd string) ([]Phase, error) {
+	phases, err := reader.importPhaseConfig(cfgPhases, wd)
+	if err != nil {
+		return nil, err
+	}
+	for i, phase := range phases {
+		tasks, err := reader.importTaskConfig(phase.Tasks, wd)
+		if err != nil {
+			return nil, err
+		}
+		phase.Tasks = tasks
+		finally, err := reader.importTaskConfig(phase.Finally, wd)
+		if err != nil {
+			return nil, err
+		}
+		phase.Finally = finally
+		phases[i] = phase
+	}
+	return phases, nil
+}
@@ -15,6 +15,11 @@ type Item struct {
 	Value interface{}
 }
 
+// Empty returns true if neither the list nor the tengo script is set.
+func (items Items) Empty() bool {
+	return items.Items == nil && items.Program.Empty()
+}
+
 func (items Items) Run(params map[string]interface{}) (interface{}, error) {
 	a, err := items.Program.Run(params)
 	if err != nil {
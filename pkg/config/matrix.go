@@ -0,0 +1,61 @@
+package config
+
+import (
+	"sort"
+)
+
+// Matrix is the named axes whose cartesian product is expanded into tasks.
+// Each axis is a list or a tengo script which returns a list.
+// The combinations which match with any exclude entries are removed,
+// and include entries are added as additional combinations.
+type Matrix struct {
+	Axes    map[string]Items
+	Include Items
+	Exclude Items
+}
+
+// Empty returns true if the matrix isn't set.
+func (matrix Matrix) Empty() bool {
+	return len(matrix.Axes) == 0 && matrix.Include.Empty()
+}
+
+// Dynamic returns true if any axes, include, or exclude is a tengo script.
+func (matrix Matrix) Dynamic() bool {
+	if !matrix.Include.Program.Empty() || !matrix.Exclude.Program.Empty() {
+		return true
+	}
+	for _, axis := range matrix.Axes {
+		if !axis.Program.Empty() {
+			return true
+		}
+	}
+	return false
+}
+
+// AxisNames returns the sorted names of axes.
+// The combinations are expanded in this order.
+func (matrix Matrix) AxisNames() []string {
+	names := make([]string, 0, len(matrix.Axes))
+	for name := range matrix.Axes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (matrix *Matrix) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	axes := map[string]Items{}
+	if err := unmarshal(&axes); err != nil {
+		return err
+	}
+	if include, ok := axes["include"]; ok {
+		matrix.Include = include
+		delete(axes, "include")
+	}
+	if exclude, ok := axes["exclude"]; ok {
+		matrix.Exclude = exclude
+		delete(axes, "exclude")
+	}
+	matrix.Axes = axes
+	return nil
+}
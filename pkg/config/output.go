@@ -1,10 +1,13 @@
 package config
 
 import (
+	"context"
+
 	"github.com/suzuki-shunsuke/buildflow/pkg/expr"
 )
 
 type Script struct {
+	Text string
 	Prog expr.Program
 }
 
@@ -12,6 +15,10 @@ func (script Script) Run(params map[string]interface{}) (interface{}, error) {
 	return script.Prog.Run(params)
 }
 
+func (script Script) RunContext(ctx context.Context, params map[string]interface{}) (interface{}, error) {
+	return script.Prog.RunContext(ctx, params)
+}
+
 func (script *Script) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	val := ""
 	if err := unmarshal(&val); err != nil {
@@ -21,6 +28,7 @@ func (script *Script) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	if err != nil {
 		return err
 	}
+	script.Text = val
 	script.Prog = prog
 	return nil
 }
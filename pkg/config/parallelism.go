@@ -0,0 +1,46 @@
+package config
+
+import (
+	"errors"
+	"runtime"
+	"strconv"
+)
+
+// Parallelism is the maximum number of tasks which are run in parallel.
+// The value should be either an integer or "auto".
+// "auto" is the number of CPUs.
+// 0 means there is no limitation.
+type Parallelism int
+
+func (parallelism *Parallelism) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var val interface{}
+	if err := unmarshal(&val); err != nil {
+		return err
+	}
+	switch v := val.(type) {
+	case int:
+		if v < 0 {
+			return errors.New("parallelism must be greater than or equal to 0: " + strconv.Itoa(v))
+		}
+		*parallelism = Parallelism(v)
+		return nil
+	case string:
+		if v != "auto" {
+			return errors.New(`parallelism must be either an integer or "auto": ` + v)
+		}
+		*parallelism = Parallelism(runtime.NumCPU())
+		return nil
+	case nil:
+		return nil
+	default:
+		return errors.New(`parallelism must be either an integer or "auto"`)
+	}
+}
+
+// String returns the description of the parallelism.
+func (parallelism Parallelism) String() string {
+	if parallelism <= 0 {
+		return "unlimited"
+	}
+	return strconv.Itoa(int(parallelism))
+}
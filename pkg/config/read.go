@@ -3,6 +3,7 @@ package config
 import (
 	"errors"
 	"os"
+	"path/filepath"
 
 	"github.com/suzuki-shunsuke/go-findconfig/findconfig"
 	"gopkg.in/yaml.v2"
@@ -29,6 +30,16 @@ func (reader Reader) read(p string) (Config, error) {
 	return cfg, nil
 }
 
+// Read reads the configuration file and imports phases and tasks.
+// Relative paths of imported files are treated as the relative path from the directory where the configuration file exists.
+func (reader Reader) Read(p string) (Config, error) {
+	cfg, err := reader.read(p)
+	if err != nil {
+		return cfg, err
+	}
+	return reader.Import(cfg, filepath.Dir(p))
+}
+
 var ErrNotFound error = errors.New("configuration file isn't found")
 
 func (reader Reader) FindAndRead(cfgPath, wd string) (Config, string, error) {
@@ -2,22 +2,45 @@ package config
 
 import (
 	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
 
 	"github.com/suzuki-shunsuke/buildflow/pkg/constant"
 	"github.com/suzuki-shunsuke/buildflow/pkg/execute"
 )
 
 type Task struct {
-	Name       Template
-	Type       string `yaml:"-"`
-	When       Bool
-	WhenFile   string `yaml:"when_file"`
-	Dependency Dependency
+	Name Template
+	Type string `yaml:"-"`
+	When Bool
+	// AllowFailure is the condition whether the failure of the task is allowed
+	AllowFailure Bool   `yaml:"allow_failure"`
+	WhenFile     string `yaml:"when_file"`
+	Dependency   Dependency
+	// If is the condition of the dependencies' results: success, failure, or always
+	If string
+	// Dir is the working directory of the task.
+	// The relative path is resolved relative to the directory where the configuration file exists.
+	Dir        Template
 	Command    Command
 	ReadFile   ReadFile  `yaml:"read_file"`
 	WriteFile  WriteFile `yaml:"write_file"`
+	Glob       Glob
 	HTTP       HTTP
+	Buildflow  Buildflow
+	Script     Script
+	ScriptFile string `yaml:"script_file"`
 	Timeout    execute.Timeout
+	Retry      Retry
+	Cache      TaskCache
+	// Resources is the number of units of resource pools which the task uses
+	Resources map[string]int
+	// Weight is the number of parallelism slots which the task uses
+	Weight     int
 	Items      Items
 	Item       Item `yaml:"-"`
 	Meta       map[string]interface{}
@@ -26,12 +49,59 @@ type Task struct {
 	InputFile  string `yaml:"input_file"`
 	OutputFile string `yaml:"output_file"`
 	Import     string
+	Matrix     Matrix
+	// Combination is the combination of the matrix which the task is expanded from
+	Combination map[string]interface{} `yaml:"-"`
+	// IsFinally is true if the task is the phase's finally task
+	IsFinally bool `yaml:"-"`
 }
 
 type WriteFile struct {
 	Path         Template
 	Template     Template
 	TemplateFile string `yaml:"template_file"`
+	Data         Data
+	Format       string
+	Append       bool
+	Mode         string
+	Mkdir        bool
+	Atomic       bool
+	FileMode     os.FileMode `yaml:"-"`
+	Content      string      `yaml:"-"`
+}
+
+func (writeFile *WriteFile) Set() error {
+	if writeFile.Append && writeFile.Atomic {
+		return errors.New("write_file.append and write_file.atomic can't be used at the same time")
+	}
+	if writeFile.Format != "" && (writeFile.Template.Text != "" || writeFile.TemplateFile != "") {
+		return errors.New("write_file.format can't be used with write_file.template and write_file.template_file")
+	}
+	switch writeFile.Format {
+	case "", "json", "yaml", "toml":
+	default:
+		return errors.New("invalid write_file.format: " + writeFile.Format)
+	}
+	if writeFile.Mode != "" {
+		mode, err := strconv.ParseUint(writeFile.Mode, 8, 32) //nolint:gomnd
+		if err != nil {
+			return fmt.Errorf("write_file.mode is invalid: %w", err)
+		}
+		writeFile.FileMode = os.FileMode(mode)
+	}
+	return nil
+}
+
+// IsLazy returns true if the task is expanded after its dependencies are finished,
+// so that the tengo script of items or matrix can refer to the outputs of dependencies.
+func (task Task) IsLazy() bool {
+	if len(task.Dependency.Names) == 0 && task.Dependency.Program.Empty() {
+		return false
+	}
+	if task.Items.Items == nil && !task.Items.Program.Empty() {
+		return true
+	}
+	return task.Matrix.Dynamic()
 }
 
 func (task *Task) Set() error {
@@ -43,6 +113,39 @@ func (task *Task) Set() error {
 		return err
 	}
 
+	switch task.If {
+	case "":
+		task.If = constant.IfSuccess
+	case constant.IfSuccess, constant.IfFailure, constant.IfAlways:
+	default:
+		return errors.New("task.if must be either success, failure, or always: " + task.If)
+	}
+
+	if task.Weight < 0 {
+		return fmt.Errorf("task.weight must be greater than or equal to 0: %d", task.Weight)
+	}
+	if task.Weight == 0 {
+		task.Weight = 1
+	}
+
+	if err := task.Retry.Set(); err != nil {
+		return fmt.Errorf("retry is invalid: %w", err)
+	}
+
+	if !task.Matrix.Empty() && !task.Items.Empty() {
+		return errors.New("matrix and items can't be used at the same time")
+	}
+
+	if task.Cache.Enabled() && task.Type != constant.Command {
+		return errors.New("cache can be used only with command tasks")
+	}
+
+	if task.Type == constant.WriteFile {
+		if err := task.WriteFile.Set(); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -59,18 +162,80 @@ func (task *Task) SetType() error {
 		task.Type = constant.WriteFile
 		return nil
 	}
-	if task.HTTP.URL != "" {
+	if len(task.Glob.Include) != 0 {
+		task.Type = constant.Glob
+		return nil
+	}
+	if task.Script.Text != "" || task.ScriptFile != "" {
+		task.Type = constant.Script
+		return nil
+	}
+	if task.Buildflow.Config.Text != "" {
+		task.Type = constant.Buildflow
+		return nil
+	}
+	if task.HTTP.URL.Text != "" {
 		task.Type = constant.HTTP
 		return nil
 	}
-	return errors.New("task must be either command, file, and http")
+	return errors.New("task must be either command, file, glob, http, buildflow, and script")
+}
+
+type Retry struct {
+	// the maximum number of attempts including the first attempt
+	MaxAttempts int `yaml:"max_attempts"`
+	// fixed or exponential
+	Backoff  string
+	Delay    time.Duration
+	MaxDelay time.Duration `yaml:"max_delay"`
+	Jitter   bool
+	When     Bool
+}
+
+func (retry *Retry) Set() error {
+	if retry.MaxAttempts < 0 {
+		return errors.New("max_attempts must not be negative")
+	}
+	switch retry.Backoff {
+	case "":
+		retry.Backoff = constant.BackoffFixed
+	case constant.BackoffFixed, constant.BackoffExponential:
+	default:
+		return errors.New("backoff must be either fixed or exponential: " + retry.Backoff)
+	}
+	retry.When.SetDefaultBool(true)
+	return nil
 }
 
 type ReadFile struct {
-	Path   Template
-	Format string
+	Path      Template
+	Format    string
+	CSVHeader bool `yaml:"csv_header"`
+	Recursive bool
+	Pattern   string
+}
+
+type Glob struct {
+	Include        []Template
+	Exclude        []Template
+	Root           Template
+	FollowSymlinks bool `yaml:"follow_symlinks"`
+}
+
+type Buildflow struct {
+	Config Template
+	Meta   Data
 }
 
 type HTTP struct {
-	URL string
+	Method         Template
+	URL            Template
+	Header         map[string]Template
+	Query          map[string]Template
+	Body           Template
+	BodyFile       string `yaml:"body_file"`
+	StatusCodes    []int  `yaml:"status_codes"`
+	Format         string
+	CompiledHeader http.Header `yaml:"-"`
+	CompiledQuery  url.Values  `yaml:"-"`
 }
@@ -7,13 +7,39 @@ const (
 	File      = "file"
 	ReadFile  = "read_file"
 	WriteFile = "write_file"
+	Glob      = "glob"
 	HTTP      = "http"
+	Buildflow = "buildflow"
+	Script    = "script"
 
 	Failed    = "failed"
 	Succeeded = "succeeded"
 	Running   = "running"
 	Skipped   = "skipped"
-	Queue     = "queue"
+	// the task failed but the failure is allowed
+	Warning = "warning"
+	// the task isn't run because the dependency failed
+	UpstreamFailed = "upstream_failed"
+	// the task is cancelled by fail_fast or the signal
+	Cancelled = "cancelled"
+	// the task is cancelled because the timeout of the phase or the build expired
+	TimedOut = "timed_out"
+	// the task isn't run because the result is restored from the cache
+	Cached = "cached"
+	Queue  = "queue"
+)
+
+// task.if
+const (
+	IfSuccess = "success"
+	IfFailure = "failure"
+	IfAlways  = "always"
+)
+
+// retry backoff
+const (
+	BackoffFixed       = "fixed"
+	BackoffExponential = "exponential"
 )
 
 // result
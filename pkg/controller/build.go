@@ -0,0 +1,104 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/suzuki-shunsuke/buildflow/pkg/config"
+	"github.com/suzuki-shunsuke/buildflow/pkg/domain"
+)
+
+type ParamsRunBuild struct {
+	ConfigPath string
+	Meta       map[string]interface{}
+	Input      interface{}
+	PR         interface{}
+	Files      interface{}
+	Stdout     io.Writer
+	Stderr     io.Writer
+	// ParentConfigPaths is the absolute paths of the configuration files of the parent builds.
+	ParentConfigPaths []string
+}
+
+// RunBuild runs the build of another configuration file in-process.
+// The pull request and the changed files of the parent build are passed to the child build.
+// If the configuration file is run by the parent builds, the build fails because it never ends.
+func (ctrl Controller) RunBuild(ctx context.Context, params ParamsRunBuild) (domain.BuildResult, error) {
+	result := domain.BuildResult{}
+	configPath, err := filepath.Abs(params.ConfigPath)
+	if err != nil {
+		return result, err
+	}
+	configPaths := make([]string, len(params.ParentConfigPaths), len(params.ParentConfigPaths)+1)
+	copy(configPaths, params.ParentConfigPaths)
+	configPaths = append(configPaths, configPath)
+	for _, p := range params.ParentConfigPaths {
+		if p == configPath {
+			return result, errors.New("the build is circular: " + strings.Join(configPaths, " -> "))
+		}
+	}
+
+	cfg, err := ctrl.ConfigReader.Read(params.ConfigPath)
+	if err != nil {
+		return result, fmt.Errorf("failed to read the configuration file %s: %w", params.ConfigPath, err)
+	}
+	cfg, err = config.Set(cfg)
+	if err != nil {
+		return result, fmt.Errorf("the configuration file %s is invalid: %w", params.ConfigPath, err)
+	}
+	if cfg.Meta == nil {
+		cfg.Meta = make(map[string]interface{}, len(params.Meta))
+	}
+	for k, v := range params.Meta {
+		cfg.Meta[k] = v
+	}
+
+	child := ctrl
+	child.Config = cfg
+	child.Stdout = params.Stdout
+	child.Stderr = params.Stderr
+	child.ConfigPaths = configPaths
+	wd := filepath.Dir(params.ConfigPath)
+	if err := child.ReadExternalFiles(ctx, wd); err != nil {
+		return result, err
+	}
+
+	buildParams := child.newParams()
+	buildParams.PR = params.PR
+	buildParams.Files = params.Files
+	buildParams.Input = params.Input
+
+	status, err := child.runBuild(ctx, buildParams, wd)
+	result.Status = status
+	result.Phases = make(map[string]interface{}, len(buildParams.Phases))
+	for k, phase := range buildParams.Phases {
+		result.Phases[k] = phase.ToTemplate()
+	}
+	return result, err
+}
+
+func (task Task) runBuildflow(ctx context.Context, params Params) (domain.BuildResult, error) {
+	meta, err := task.Config.Buildflow.Meta.Run(params.ToExpr())
+	if err != nil {
+		return domain.BuildResult{}, fmt.Errorf("failed to evaluate buildflow.meta: %w", err)
+	}
+	m, ok := meta.(map[string]interface{})
+	if meta != nil && !ok {
+		return domain.BuildResult{}, errors.New("buildflow.meta should be a map")
+	}
+	return task.Builder.RunBuild(ctx, ParamsRunBuild{
+		ConfigPath: task.Config.Buildflow.Config.Text,
+		Meta:       m,
+		Input:      task.Result.Input,
+		PR:         params.PR,
+		Files:      params.Files,
+		Stdout:     task.Stdout,
+		Stderr:     task.Stderr,
+
+		ParentConfigPaths: task.configPaths,
+	})
+}
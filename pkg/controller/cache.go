@@ -0,0 +1,108 @@
+package controller
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/suzuki-shunsuke/buildflow/pkg/cache"
+	"github.com/suzuki-shunsuke/buildflow/pkg/config"
+	"github.com/suzuki-shunsuke/buildflow/pkg/file"
+)
+
+// cacheInputs is the inputs of the cache key except for the contents of sources.
+type cacheInputs struct {
+	Shell     string
+	ShellOpts []string
+	Command   string
+	Stdin     string
+	Env       []string
+	Dir       string
+	Sources   []string
+	Outputs   []string
+	Keys      []string
+	EnvVars   []string
+}
+
+// globFiles returns the relative paths of files under the task's working directory which match with patterns.
+func (task Task) globFiles(patterns []config.Template) ([]string, error) {
+	if len(patterns) == 0 {
+		return nil, nil
+	}
+	results, err := task.FileReader.Glob(file.ParamsGlob{
+		Root:    task.Config.Dir.Text,
+		Include: renderedTexts(patterns),
+	})
+	if err != nil {
+		return nil, err
+	}
+	files := make([]string, 0, len(results))
+	for _, result := range results {
+		if result.IsDir {
+			continue
+		}
+		rel, err := filepath.Rel(task.Config.Dir.Text, result.Path)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, filepath.ToSlash(rel))
+	}
+	return files, nil
+}
+
+// cacheKey returns the cache key of the rendered task.
+// If the cache is disabled, an empty string is returned.
+func (task Task) cacheKey() (string, error) {
+	if task.Cache == nil || !task.Config.Cache.Enabled() {
+		return "", nil
+	}
+	sources, err := task.globFiles(task.Config.Cache.Sources)
+	if err != nil {
+		return "", err
+	}
+	envVars := make([]string, len(task.Config.Cache.Env))
+	for i, name := range task.Config.Cache.Env {
+		envVars[i] = name + "=" + os.Getenv(name)
+	}
+	cmd := task.Config.Command
+	return cache.Key(cacheInputs{
+		Shell:     cmd.Shell,
+		ShellOpts: cmd.ShellOpts,
+		Command:   cmd.Command.Text,
+		Stdin:     cmd.Stdin.Text,
+		Env:       cmd.Env.Compiled,
+		Dir:       task.Config.Dir.Text,
+		Sources:   renderedTexts(task.Config.Cache.Sources),
+		Outputs:   renderedTexts(task.Config.Cache.Outputs),
+		Keys:      renderedTexts(task.Config.Cache.Keys),
+		EnvVars:   envVars,
+	}, task.Config.Dir.Text, sources)
+}
+
+// restoreCache restores the result and outputs of the task from the cache.
+// If the cache isn't found, false is returned.
+func (task Task) restoreCache(key string) (Task, bool, error) {
+	entry, found, err := task.Cache.Get(key)
+	if err != nil || !found {
+		return task, false, err
+	}
+	if err := task.Cache.Restore(key, task.Config.Dir.Text, entry); err != nil {
+		return task, false, err
+	}
+	now := task.Timer.Now()
+	task.Result.Command = entry.Result
+	task.Result.Time.Start = now
+	task.Result.Time.End = now
+	return task, true, nil
+}
+
+// saveCache stores the result and outputs of the task which succeeded.
+func (task Task) saveCache(key string) error {
+	files, err := task.globFiles(task.Config.Cache.Outputs)
+	if err != nil {
+		return err
+	}
+	return task.Cache.Put(key, task.Config.Dir.Text, cache.Entry{
+		Result: task.Result.Command,
+		Files:  files,
+	})
+}
@@ -1,6 +1,7 @@
 package controller
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
@@ -15,7 +16,9 @@ import (
 	"github.com/suzuki-shunsuke/buildflow/pkg/domain"
 	"github.com/suzuki-shunsuke/buildflow/pkg/execute"
 	"github.com/suzuki-shunsuke/buildflow/pkg/expr"
+	"github.com/suzuki-shunsuke/buildflow/pkg/git"
 	gh "github.com/suzuki-shunsuke/buildflow/pkg/github"
+	"github.com/suzuki-shunsuke/buildflow/pkg/signal"
 	"github.com/suzuki-shunsuke/buildflow/pkg/template"
 	"github.com/suzuki-shunsuke/go-dataeq/dataeq"
 )
@@ -23,22 +26,73 @@ import (
 type Params struct {
 	PR        interface{}
 	Files     interface{}
+	Input     interface{}
 	Phases    map[string]Phase
 	TaskIdx   int
 	PhaseName string
 	Item      config.Item
+	Matrix    map[string]interface{}
 	Meta      map[string]interface{}
+	// BuildStatus is the status of the build.
+	// It is running until the phases other than finally phases are finished.
+	BuildStatus string
 }
 
 type TaskList struct {
 	tasks []Task
+	// templates caches the template parameters of tasks
+	templates []map[string]interface{}
+	// array caches the result of ToTemplate.
+	// The array may be referred by running templates, so it isn't updated but rebuilt after tasks are updated.
+	array []interface{}
 	mutex sync.RWMutex
 }
 
 func (list *TaskList) Set(idx int, task Task) {
 	list.mutex.Lock()
 	list.tasks[idx] = task
+	if list.templates != nil {
+		list.templates[idx] = nil
+	}
+	list.array = nil
+	list.mutex.Unlock()
+}
+
+// Append adds tasks to the end of the list and returns the index of the first added task.
+func (list *TaskList) Append(tasks ...Task) int {
+	list.mutex.Lock()
+	idx := len(list.tasks)
+	list.tasks = append(list.tasks, tasks...)
+	if list.templates != nil {
+		list.templates = append(list.templates, make([]map[string]interface{}, len(tasks))...)
+	}
+	list.array = nil
 	list.mutex.Unlock()
+	return idx
+}
+
+// ToTemplate returns the template parameters of tasks.
+// The parameter of each task and the returned array are cached until the task is updated,
+// so the returned array and maps must not be modified.
+func (list *TaskList) ToTemplate() []interface{} {
+	list.mutex.Lock()
+	defer list.mutex.Unlock()
+	if list.array != nil {
+		return list.array
+	}
+	if list.templates == nil {
+		list.templates = make([]map[string]interface{}, len(list.tasks))
+	}
+	arr := make([]interface{}, len(list.tasks))
+	for i := range list.tasks {
+		// the task isn't copied because Task is a large struct
+		if list.templates[i] == nil {
+			list.templates[i] = list.tasks[i].ToTemplate()
+		}
+		arr[i] = list.templates[i]
+	}
+	list.array = arr
+	return arr
 }
 
 func (list *TaskList) GetAll() []Task {
@@ -69,13 +123,9 @@ func (list *TaskList) Get(idx int) Task {
 }
 
 func (phase Phase) ToTemplate() map[string]interface{} {
-	tasks := make([]interface{}, phase.Tasks.Size())
-	for i, task := range phase.Tasks.GetAll() {
-		tasks[i] = task.ToTemplate()
-	}
 	return map[string]interface{}{
 		"Status": phase.Status,
-		"Tasks":  tasks,
+		"Tasks":  phase.Tasks.ToTemplate(),
 		"Meta":   phase.Meta(),
 		"Name":   phase.Name(),
 	}
@@ -93,7 +143,17 @@ func (task Task) ToTemplate() map[string]interface{} {
 		"Meta":   task.Config.Meta,
 		"Output": task.Result.Output,
 		"Input":  task.Result.Input,
+		// the number of the current attempt, which starts from 1
+		"Attempt": len(task.Result.Attempts) + 1,
 	}
+	attempts := make([]interface{}, len(task.Result.Attempts))
+	for i, result := range task.Result.Attempts {
+		attempts[i] = Task{
+			Config: task.Config,
+			Result: result,
+		}.ToTemplate()
+	}
+	m["Attempts"] = attempts
 	switch task.Config.Type {
 	case constant.Command:
 		m["ExitCode"] = task.Result.Command.ExitCode
@@ -101,8 +161,17 @@ func (task Task) ToTemplate() map[string]interface{} {
 		m["Stderr"] = task.Result.Command.Stderr
 		m["CombinedOutput"] = task.Result.Command.CombinedOutput
 	case constant.HTTP:
+		m["HTTP"] = task.Result.HTTP.ToTemplate()
+	case constant.Buildflow:
+		m["Build"] = task.Result.Build.ToTemplate()
 	case constant.ReadFile, constant.WriteFile:
 		m["File"] = task.Result.File.ToTemplate()
+	case constant.Glob:
+		files := make([]interface{}, len(task.Result.Glob))
+		for i, f := range task.Result.Glob {
+			files[i] = f.ToTemplate()
+		}
+		m["Glob"] = files
 	}
 	return m
 }
@@ -132,19 +201,20 @@ func (params Params) ToTemplate() map[string]interface{} {
 			"Key":   params.Item.Key,
 			"Value": params.Item.Value,
 		},
-		"Meta": params.Meta,
+		"Matrix": params.Matrix,
+		"Meta":   params.Meta,
+		"Input":  params.Input,
+		"Build": map[string]interface{}{
+			"Status": params.BuildStatus,
+		},
 	}
 
 	var tasks []interface{}
 	if params.PhaseName != "" {
-		t := params.Phases[params.PhaseName].Tasks
-		pTasks := t.GetAll()
-		tasks = make([]interface{}, len(pTasks))
-		for i, task := range pTasks {
-			tasks[i] = task.ToTemplate()
-		}
-
-		m["Phase"] = params.Phases[params.PhaseName].ToTemplate()
+		// the parameter of the current phase is shared
+		phase := phases[params.PhaseName].(map[string]interface{})
+		tasks = phase["Tasks"].([]interface{})
+		m["Phase"] = phase
 	}
 	m["Tasks"] = tasks
 	return m
@@ -154,34 +224,56 @@ func (params Params) ToExpr() map[string]interface{} {
 	return params.ToTemplate()
 }
 
+// matchBool evaluates the condition.
+// If the condition is fixed, params isn't converted to the map to avoid the unnecessary cost.
+func matchBool(b config.Bool, params Params) (bool, error) {
+	if b.Fixed {
+		return b.FixedValue, nil
+	}
+	return b.Match(params.ToExpr())
+}
+
+func (ctrl Controller) newTask(taskCfg config.Task) Task {
+	return Task{
+		Config: taskCfg,
+		Result: domain.Result{
+			Status: "queue",
+		},
+		Executor:   ctrl.Executor,
+		Stdout:     execute.NewWriter(ctrl.Stdout, taskCfg.Name.Text),
+		Stderr:     execute.NewWriter(ctrl.Stderr, taskCfg.Name.Text),
+		Timer:      ctrl.Timer,
+		FileReader: ctrl.FileReader,
+		FileWriter: ctrl.FileWriter,
+		HTTPClient: ctrl.HTTPClient,
+		Builder:    ctrl,
+		Cache:      ctrl.Cache,
+
+		configPaths: ctrl.ConfigPaths,
+	}
+}
+
 func (ctrl Controller) newPhase(phaseCfg config.Phase) Phase {
 	tasks := make([]Task, len(phaseCfg.Tasks))
 	for i, taskCfg := range phaseCfg.Tasks {
-		task := Task{
-			Config: taskCfg,
-			Result: domain.Result{
-				Status: "queue",
-			},
-			Executor:   ctrl.Executor,
-			Stdout:     execute.NewWriter(ctrl.Stdout, taskCfg.Name.Text),
-			Stderr:     execute.NewWriter(ctrl.Stderr, taskCfg.Name.Text),
-			Timer:      ctrl.Timer,
-			FileReader: ctrl.FileReader,
-			FileWriter: ctrl.FileWriter,
-		}
-		tasks[i] = task
+		tasks[i] = ctrl.newTask(taskCfg)
+	}
+	indices := make(map[string][]int, len(tasks))
+	for i, task := range tasks {
+		indices[task.Name()] = append(indices[task.Name()], i)
 	}
 	return Phase{
 		Config: phaseCfg,
 		Tasks: &TaskList{
 			tasks: tasks,
 		},
-		EventQueue: &EventQueue{
-			Queue: make(chan struct{}, len(tasks)),
-		},
-		Stdout:    ctrl.Stdout,
-		Stderr:    ctrl.Stderr,
-		TaskQueue: newTaskQueue(ctrl.Config.Parallelism),
+		// each task sends the event only once, so the channel doesn't block
+		// unless tasks are added by the lazy expansion
+		events:       make(chan int, len(tasks)),
+		indices:      indices,
+		Stdout:       ctrl.Stdout,
+		Stderr:       ctrl.Stderr,
+		ResourcePool: ctrl.resourcePool,
 	}
 }
 
@@ -224,7 +316,7 @@ func (ctrl Controller) getPR(ctx context.Context) (*github.PullRequest, error) {
 	return pr, nil
 }
 
-func (ctrl Controller) getParams(ctx context.Context, pr *github.PullRequest) (Params, error) {
+func (ctrl Controller) newParams() Params {
 	params := Params{
 		Meta:    ctrl.Config.Meta,
 		Phases:  make(map[string]Phase, len(ctrl.Config.Phases)),
@@ -237,6 +329,37 @@ func (ctrl Controller) getParams(ctx context.Context, pr *github.PullRequest) (P
 			},
 		}
 	}
+	return params
+}
+
+// getChangedFiles gets changed files by git instead of GitHub API.
+func (ctrl Controller) getChangedFiles(ctx context.Context, wd string) ([]interface{}, error) {
+	baseBranch := ctrl.Config.Git.BaseBranch
+	if baseBranch == "" {
+		baseBranch = ctrl.Config.Env.PRBaseBranch
+	}
+	files, err := ctrl.Git.ChangedFiles(ctx, git.ParamsChangedFiles{
+		WorkingDir: wd,
+		BaseBranch: baseBranch,
+		Staged:     ctrl.Config.Git.Staged,
+	})
+	if err != nil {
+		return nil, err
+	}
+	logrus.WithFields(logrus.Fields{
+		"base_branch":   baseBranch,
+		"staged":        ctrl.Config.Git.Staged,
+		"changed_files": len(files),
+	}).Debug("the number of changed files gotten by git")
+	arr := make([]interface{}, len(files))
+	for i, file := range files {
+		arr[i] = file.ToTemplate()
+	}
+	return arr, nil
+}
+
+func (ctrl Controller) getParams(ctx context.Context, pr *github.PullRequest) (Params, error) {
+	params := ctrl.newParams()
 
 	if pr == nil {
 		logrus.Debug("pr is nil")
@@ -273,27 +396,86 @@ func (ctrl Controller) getParams(ctx context.Context, pr *github.PullRequest) (P
 
 func (ctrl Controller) getPhase(params Params, phaseCfg config.Phase) Phase {
 	params.PhaseName = phaseCfg.Name
-	phase := Phase{
-		Tasks: &TaskList{
-			tasks: []Task{},
-		},
-		Config: phaseCfg,
+	mainTasks := []config.Task{}
+	for _, task := range phaseCfg.Tasks {
+		// finally tasks are run by runFinallyTasks
+		if !task.IsFinally {
+			mainTasks = append(mainTasks, task)
+		}
 	}
+	tasksCfg, lazy, err := expandTasks(mainTasks, params)
+	if err != nil {
+		// the phase has no task but finally tasks can be run
+		phaseCfg.Tasks = nil
+		phase := ctrl.newPhase(phaseCfg)
+		phase.Error = err
+		return phase
+	}
+	phaseCfg.Tasks = tasksCfg
+	phase := ctrl.newPhase(phaseCfg)
+	for _, idx := range lazy {
+		task := phase.Tasks.Get(idx)
+		task.lazy = true
+		phase.Tasks.Set(idx, task)
+	}
+	return phase
+}
 
+// expandTasks expands tasks except for lazy tasks, which are expanded by the scheduler
+// after their dependencies are finished.
+// The indices of lazy tasks are returned.
+func expandTasks(cfgs []config.Task, params Params) ([]config.Task, []int, error) {
 	tasksCfg := []config.Task{}
-	for _, task := range phaseCfg.Tasks {
+	lazy := []int{}
+	for _, task := range cfgs {
+		if task.IsLazy() {
+			lazy = append(lazy, len(tasksCfg))
+			tasksCfg = append(tasksCfg, task)
+			continue
+		}
 		tasks, err := Expand(task, params)
 		if err != nil {
-			phase.Error = err
-			break
+			return nil, nil, err
 		}
 		tasksCfg = append(tasksCfg, tasks...)
 	}
-	if phase.Error != nil {
-		return phase
+	return tasksCfg, lazy, nil
+}
+
+// runFinallyTasks runs the phase's finally tasks after the other tasks are finished.
+// Finally tasks are added to the phase, so they can refer to the results of other tasks.
+// They are run even if the phase failed or was cancelled,
+// so they aren't cancelled by fail_fast, the timeout of the phase, and the cancellation of the build.
+// They are cancelled only when the signal is sent again.
+func (ctrl Controller) runFinallyTasks(ctx context.Context, params Params, phase *Phase, phaseCfg config.Phase, wd string) error {
+	cfgs := []config.Task{}
+	for _, task := range phaseCfg.Tasks {
+		if task.IsFinally {
+			cfgs = append(cfgs, task)
+		}
 	}
-	phaseCfg.Tasks = tasksCfg
-	return ctrl.newPhase(phaseCfg)
+	if len(cfgs) == 0 {
+		return nil
+	}
+	tasksCfg, lazy, err := expandTasks(cfgs, params)
+	if err != nil {
+		return fmt.Errorf("failed to expand finally tasks: %w", err)
+	}
+	tasks := make([]Task, len(tasksCfg))
+	for i, taskCfg := range tasksCfg {
+		tasks[i] = ctrl.newTask(taskCfg)
+	}
+	for _, idx := range lazy {
+		tasks[idx].lazy = true
+	}
+	first := phase.Tasks.Append(tasks...)
+	for i, task := range tasks {
+		phase.indices[task.Name()] = append(phase.indices[task.Name()], first+i)
+	}
+	finally := *phase
+	finally.Config.FailFast = nil
+	finally.Config.Timeout = 0
+	return finally.Run(signal.Detach(ctx), params, wd)
 }
 
 func (ctrl Controller) checkSkipPhase(params Params, phase Phase, phaseCfg config.Phase) (Phase, bool) {
@@ -311,19 +493,30 @@ func (ctrl Controller) checkSkipPhase(params Params, phase Phase, phaseCfg confi
 	return phase, false
 }
 
+// printPhaseHeader is called in the goroutine of the phase,
+// so the header is written at once so that it isn't interleaved with the results of other phases.
 func (ctrl Controller) printPhaseHeader(phase Phase) {
-	fmt.Fprintln(phase.Stderr, "\n==============")
-	fmt.Fprintln(phase.Stderr, "= Phase: "+phase.Config.Name+" =")
-	fmt.Fprintln(phase.Stderr, "==============")
+	buf := &bytes.Buffer{}
+	fmt.Fprintln(buf, "\n==============")
+	fmt.Fprintln(buf, "= Phase: "+phase.Config.Name+" =")
+	fmt.Fprintln(buf, "==============")
+	fmt.Fprintln(buf, "parallelism:", phase.Config.Parallelism, "(build: "+ctrl.Config.Parallelism.String()+")")
+	phase.Stderr.Write(buf.Bytes()) //nolint:errcheck
 }
 
-func (ctrl Controller) runPhase(ctx context.Context, params Params, idx int, wd string) (Phase, error) {
-	phaseCfg := ctrl.Config.Phases[idx]
+func (ctrl Controller) runPhase(ctx context.Context, params Params, phaseCfg config.Phase, wd string) (Phase, error) {
 	if len(phaseCfg.Tasks) == 0 {
 		return Phase{}, nil
 	}
 	phase := ctrl.getPhase(params, phaseCfg)
 	if phase.Error != nil {
+		// finally tasks are run even if tasks can't be expanded
+		params.PhaseName = phase.Name()
+		params.Phases[params.PhaseName] = phase
+		ctrl.printPhaseHeader(phase)
+		if err := ctrl.runFinallyTasks(ctx, params, &phase, phaseCfg, wd); err != nil {
+			log.Println(err)
+		}
 		return phase, nil
 	}
 	params.PhaseName = phase.Name()
@@ -335,19 +528,13 @@ func (ctrl Controller) runPhase(ctx context.Context, params Params, idx int, wd
 		phase = p
 	}
 
-	phase.EventQueue.Push()
-	go func() {
-		<-ctx.Done()
-		phase.EventQueue.Close()
-	}()
 	params.Phases[phaseCfg.Name] = phase
 	ctrl.printPhaseHeader(phase)
-	for range phase.EventQueue.Queue {
-		if err := phase.Run(ctx, params, wd); err != nil {
-			phase.EventQueue.Close()
-			log.Println(err)
-		}
-		params.Phases[phaseCfg.Name] = phase
+	if err := phase.Run(ctx, params, wd); err != nil {
+		log.Println(err)
+	}
+	if err := ctrl.runFinallyTasks(ctx, params, &phase, phaseCfg, wd); err != nil {
+		log.Println(err)
 	}
 	params.Phases[phaseCfg.Name] = phase
 
@@ -359,6 +546,10 @@ func (ctrl Controller) runPhase(ctx context.Context, params Params, idx int, wd
 	} else {
 		phase.Status = constant.Succeeded
 	}
+	if phase.Status == constant.Succeeded && ctx.Err() != nil && phase.hasCancelledTask() {
+		// the phase is interrupted
+		phase.Status = constant.Cancelled
+	}
 
 	if f, err := phaseCfg.Condition.Exit.Match(params.ToExpr()); err != nil {
 		return phase, err
@@ -370,7 +561,106 @@ func (ctrl Controller) runPhase(ctx context.Context, params Params, idx int, wd
 	return phase, nil
 }
 
-var ErrBuildFail = errors.New("build failed")
+var (
+	ErrBuildFail      = errors.New("build failed")
+	ErrBuildCancelled = errors.New("the build is cancelled")
+)
+
+type phaseResult struct {
+	idx   int
+	phase Phase
+	err   error
+}
+
+// runPhases runs phases in the order of depends_on.
+// Phases whose dependencies are finished are run in parallel.
+// Each phase is given a copy of params.Phases so that the map isn't updated concurrently,
+// and the results of phases are output one by one when they are finished.
+// If a phase exits the build or returns an error, new phases aren't started and running phases are waited for.
+func (ctrl Controller) runPhases(ctx context.Context, params Params, wd string) error {
+	phases := ctrl.Config.Phases
+	deps := config.PhaseDependencies(phases)
+	started := make([]bool, len(phases))
+	finished := make([]bool, len(phases))
+	results := make(chan phaseResult)
+	running := 0
+	stopped := false
+	var buildErr error
+	for {
+		if ctx.Err() != nil {
+			// new phases aren't started after the build is cancelled
+			stopped = true
+		}
+		if !stopped {
+			for i := range phases {
+				// finally phases are run by runFinallyPhases
+				if started[i] || phases[i].IsFinally || !isPhaseReady(deps[i], finished) {
+					continue
+				}
+				started[i] = true
+				running++
+				p := params
+				p.Phases = make(map[string]Phase, len(params.Phases))
+				for k, v := range params.Phases {
+					p.Phases[k] = v
+				}
+				go func(idx int, p Params) {
+					phase, err := ctrl.runPhase(ctx, p, phases[idx], wd)
+					results <- phaseResult{idx: idx, phase: phase, err: err}
+				}(i, p)
+			}
+		}
+		if running == 0 {
+			if ctx.Err() != nil {
+				ctrl.outputCancelledPhases(ctx, started)
+			}
+			return buildErr
+		}
+		result := <-results
+		running--
+		finished[result.idx] = true
+		name := phases[result.idx].Name
+		phase := result.phase
+		if phase.Error != nil {
+			phase.Status = constant.Failed
+		}
+		params.Phases[name] = phase
+		phase.outputResult(ctrl.Stderr, name)
+		if result.err != nil {
+			if buildErr == nil {
+				buildErr = result.err
+			}
+			stopped = true
+		}
+		if phase.Exit {
+			stopped = true
+		}
+	}
+}
+
+// outputCancelledPhases outputs the results of phases which aren't run because the build is cancelled.
+func (ctrl Controller) outputCancelledPhases(ctx context.Context, started []bool) {
+	for i, phaseCfg := range ctrl.Config.Phases {
+		if started[i] || phaseCfg.IsFinally {
+			continue
+		}
+		phase := Phase{
+			Config: phaseCfg,
+			Status: cancelledStatus(ctx),
+			Error:  errors.New("the phase isn't run because the build is cancelled"),
+		}
+		phase.outputResult(ctrl.Stderr, phaseCfg.Name)
+	}
+}
+
+func isPhaseReady(deps []int, finished []bool) bool {
+	for _, idx := range deps {
+		if !finished[idx] {
+			return false
+		}
+	}
+	return true
+}
 
 func (ctrl Controller) readTemplateFile(p, wd string, tpl *config.Template) error {
 	if p == "" {
@@ -403,6 +693,7 @@ func (ctrl Controller) readScript(p, wd string, scr *config.Script) error {
 	if prog, err := expr.New(result.Text); err != nil {
 		return err
 	} else {
+		scr.Text = result.Text
 		scr.Prog = prog
 	}
 	return nil
@@ -459,6 +750,14 @@ func (ctrl Controller) ReadExternalFiles(ctx context.Context, wd string) error {
 					return err
 				}
 			}
+			if task.HTTP.BodyFile != "" {
+				if err := ctrl.readTemplateFile(task.HTTP.BodyFile, wd, &task.HTTP.Body); err != nil {
+					return err
+				}
+			}
+			if err := ctrl.readScript(task.ScriptFile, wd, &task.Script); err != nil {
+				return err
+			}
 			if err := ctrl.readScript(task.InputFile, wd, &task.Input); err != nil {
 				return err
 			}
@@ -497,33 +796,101 @@ func (ctrl Controller) Run(ctx context.Context, wd string) error {
 		return err
 	}
 
+	if ctrl.Config.Git.ChangedFiles {
+		files, err := ctrl.getChangedFiles(ctx, wd)
+		if err != nil {
+			return fmt.Errorf("failed to get changed files by git: %w", err)
+		}
+		params.Files = files
+	}
+
+	_, err = ctrl.runBuild(ctx, params, wd)
+	if ctrl.Cache != nil {
+		if e := ctrl.Cache.Evict(); e != nil {
+			logrus.WithError(e).Warn("failed to evict the cache")
+		}
+	}
+	return err
+}
+
+// runBuild runs phases and returns the build status.
+func (ctrl Controller) runBuild(ctx context.Context, params Params, wd string) (string, error) {
 	if f, err := ctrl.Config.Condition.Skip.Match(params.ToExpr()); err != nil {
-		return err
+		return constant.Failed, err
 	} else if f {
 		fmt.Fprintln(ctrl.Stderr, "the build is skipped")
-		return nil
+		return constant.Skipped, nil
 	}
 
-	for i, phaseCfg := range ctrl.Config.Phases {
-		phase, err := ctrl.runPhase(ctx, params, i, wd)
+	// the parallelism and resource pools are shared by phases which are run in parallel
+	ctrl.resourcePool = newResourcePool(ctrl.Config)
+	params.BuildStatus = constant.Running
+	status, err := ctrl.runMainPhases(ctx, params, wd)
+	params.BuildStatus = status
+	failed, finallyErr := ctrl.runFinallyPhases(ctx, params, wd)
+	if err != nil {
+		return status, err
+	}
+	if finallyErr != nil {
+		return constant.Failed, finallyErr
+	}
+	if failed {
+		return constant.Failed, ErrBuildFail
+	}
+	return status, nil
+}
+
+// runMainPhases runs phases other than finally phases and returns the build status.
+func (ctrl Controller) runMainPhases(ctx context.Context, params Params, wd string) (string, error) {
+	if ctrl.Config.Timeout > 0 {
+		c, cancel := context.WithTimeout(ctx, ctrl.Config.Timeout)
+		defer cancel()
+		ctx = c
+	}
+
+	if err := ctrl.runPhases(ctx, params, wd); err != nil {
+		return constant.Failed, err
+	}
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return constant.Failed, fmt.Errorf("the build timed out (timeout: %s)", ctrl.Config.Timeout)
+	}
+	if ctx.Err() != nil {
+		return constant.Cancelled, ErrBuildCancelled
+	}
+
+	if f, err := ctrl.Config.Condition.Fail.Match(params.ToExpr()); err != nil {
+		return constant.Failed, err
+	} else if f {
+		return constant.Failed, ErrBuildFail
+	}
+
+	return constant.Succeeded, nil
+}
+
+// runFinallyPhases runs finally phases sequentially after the other phases are finished.
+// Finally phases are run even if the build failed, exited, or was cancelled,
+// so they aren't cancelled by the timeout and the cancellation of the build.
+// They are cancelled only when the signal is sent again.
+// If any finally phase fails, true is returned.
+func (ctrl Controller) runFinallyPhases(ctx context.Context, params Params, wd string) (bool, error) {
+	ctx = signal.Detach(ctx)
+	failed := false
+	for _, phaseCfg := range ctrl.Config.Phases {
+		if !phaseCfg.IsFinally {
+			continue
+		}
+		phase, err := ctrl.runPhase(ctx, params, phaseCfg, wd)
 		if phase.Error != nil {
 			phase.Status = constant.Failed
 		}
 		params.Phases[phaseCfg.Name] = phase
 		phase.outputResult(ctrl.Stderr, phaseCfg.Name)
 		if err != nil {
-			return err
+			return true, err
 		}
-		if phase.Exit {
-			break
+		if phase.Status == constant.Failed {
+			failed = true
 		}
 	}
-
-	if f, err := ctrl.Config.Condition.Fail.Match(params.ToExpr()); err != nil {
-		return err
-	} else if f {
-		return ErrBuildFail
-	}
-
-	return nil
+	return failed, nil
 }
@@ -6,14 +6,14 @@ import (
 	"github.com/suzuki-shunsuke/buildflow/pkg/config"
 )
 
-func renderEnvs(envs config.Envs, params Params) ([]string, error) {
+func renderEnvs(envs config.Envs, tplParams map[string]interface{}) ([]string, error) {
 	m := make([]string, len(envs.Vars))
 	for i, env := range envs.Vars {
-		k, err := env.Key.Render(params.ToTemplate())
+		k, err := env.Key.Render(tplParams)
 		if err != nil {
 			return nil, fmt.Errorf(`failed to render env key %d: %w`, i, err)
 		}
-		v, err := env.Value.Render(params.ToTemplate())
+		v, err := env.Value.Render(tplParams)
 		if err != nil {
 			return nil, fmt.Errorf(`failed to render env value %d: %w`, i, err)
 		}
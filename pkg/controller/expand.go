@@ -2,9 +2,11 @@ package controller
 
 import (
 	"errors"
+	"fmt"
 	"reflect"
 
 	"github.com/suzuki-shunsuke/buildflow/pkg/config"
+	"github.com/suzuki-shunsuke/go-dataeq/dataeq"
 )
 
 func expandSlice(task config.Task, value reflect.Value, params Params) ([]config.Task, error) {
@@ -73,6 +75,9 @@ func expandItems(task config.Task, items interface{}, templateParams Params) ([]
 }
 
 func Expand(task config.Task, params Params) ([]config.Task, error) {
+	if !task.Matrix.Empty() {
+		return expandMatrix(task, params)
+	}
 	if task.Items.Items != nil {
 		return expandItems(task, task.Items.Items, params)
 	}
@@ -91,3 +96,159 @@ func Expand(task config.Task, params Params) ([]config.Task, error) {
 	}
 	return expandItems(task, items, params)
 }
+
+// evaluateList returns the list of the static list or the result of the tengo script.
+func evaluateList(items config.Items, params Params) ([]interface{}, error) {
+	val := items.Items
+	if val == nil {
+		v, err := items.Run(params.ToExpr())
+		if err != nil {
+			return nil, err
+		}
+		val = v
+	}
+	if val == nil {
+		return nil, nil
+	}
+	value := reflect.ValueOf(val)
+	if value.Kind() != reflect.Slice {
+		return nil, errors.New("the value should be a list. invalid kind: " + value.Kind().String())
+	}
+	arr := make([]interface{}, value.Len())
+	for i := range arr {
+		arr[i] = value.Index(i).Interface()
+	}
+	return arr, nil
+}
+
+// evaluateCombinations returns the list of maps such as matrix.include and matrix.exclude.
+func evaluateCombinations(items config.Items, params Params) ([]map[string]interface{}, error) {
+	list, err := evaluateList(items, params)
+	if err != nil {
+		return nil, err
+	}
+	combinations := make([]map[string]interface{}, len(list))
+	for i, elem := range list {
+		m, ok := elem.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("the element should be a map: %v", elem)
+		}
+		combinations[i] = m
+	}
+	return combinations, nil
+}
+
+// matchCombination returns true if the combination has all values of the entry.
+// Values are compared as JSON, so the integer of YAML equals to the integer of tengo.
+func matchCombination(combination, entry map[string]interface{}) (bool, error) {
+	for k, v := range entry {
+		val, ok := combination[k]
+		if !ok {
+			return false, nil
+		}
+		if f, err := dataeq.JSON.Equal(val, v); err != nil || !f {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// containsMatch returns true if the combination matches with any entries.
+func containsMatch(entries []map[string]interface{}, combination map[string]interface{}) (bool, error) {
+	for _, entry := range entries {
+		if f, err := matchCombination(combination, entry); err != nil || f {
+			return f, err
+		}
+	}
+	return false, nil
+}
+
+// containsCombination returns true if the same combination exists.
+func containsCombination(combinations []map[string]interface{}, combination map[string]interface{}) (bool, error) {
+	for _, c := range combinations {
+		if len(c) != len(combination) {
+			continue
+		}
+		if f, err := matchCombination(c, combination); err != nil || f {
+			return f, err
+		}
+	}
+	return false, nil
+}
+
+// matrixCombinations returns the cartesian product of axes.
+// The combinations which match with any exclude entries are removed, and include entries are added.
+func matrixCombinations(matrix config.Matrix, params Params) ([]map[string]interface{}, error) {
+	combinations := []map[string]interface{}{}
+	for i, name := range matrix.AxisNames() {
+		values, err := evaluateList(matrix.Axes[name], params)
+		if err != nil {
+			return nil, fmt.Errorf("failed to evaluate matrix.%s: %w", name, err)
+		}
+		if i == 0 {
+			combinations = append(combinations, map[string]interface{}{})
+		}
+		product := make([]map[string]interface{}, 0, len(combinations)*len(values))
+		for _, combination := range combinations {
+			for _, value := range values {
+				c := make(map[string]interface{}, len(combination)+1)
+				for k, v := range combination {
+					c[k] = v
+				}
+				c[name] = value
+				product = append(product, c)
+			}
+		}
+		combinations = product
+	}
+
+	excludes, err := evaluateCombinations(matrix.Exclude, params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to evaluate matrix.exclude: %w", err)
+	}
+	filtered := make([]map[string]interface{}, 0, len(combinations))
+	for _, combination := range combinations {
+		excluded, err := containsMatch(excludes, combination)
+		if err != nil {
+			return nil, err
+		}
+		if !excluded {
+			filtered = append(filtered, combination)
+		}
+	}
+
+	includes, err := evaluateCombinations(matrix.Include, params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to evaluate matrix.include: %w", err)
+	}
+	for _, include := range includes {
+		f, err := containsCombination(filtered, include)
+		if err != nil {
+			return nil, err
+		}
+		if !f {
+			filtered = append(filtered, include)
+		}
+	}
+	return filtered, nil
+}
+
+func expandMatrix(task config.Task, params Params) ([]config.Task, error) {
+	combinations, err := matrixCombinations(task.Matrix, params)
+	if err != nil {
+		return nil, err
+	}
+	tasks := make([]config.Task, len(combinations))
+	for i, combination := range combinations {
+		params.Matrix = combination
+		name, err := task.Name.New(params.ToTemplate())
+		if err != nil {
+			return nil, err
+		}
+		t := task
+		t.Name = name
+		t.Combination = combination
+		tasks[i] = t
+	}
+	return tasks, nil
+}
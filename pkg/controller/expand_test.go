@@ -0,0 +1,102 @@
+package controller_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/suzuki-shunsuke/buildflow/pkg/config"
+	"github.com/suzuki-shunsuke/buildflow/pkg/controller"
+	"gopkg.in/yaml.v2"
+)
+
+func TestExpand_matrix(t *testing.T) {
+	data := []struct {
+		title string
+		task  string
+		exp   []string
+		isErr bool
+	}{
+		{
+			title: "cartesian product",
+			task: `
+name: "test {{.Matrix.go}} {{.Matrix.os}}"
+command:
+  command: echo test
+matrix:
+  os: [ubuntu, alpine]
+  go: ["1.15", "1.16"]
+`,
+			exp: []string{"test 1.15 ubuntu", "test 1.15 alpine", "test 1.16 ubuntu", "test 1.16 alpine"},
+		},
+		{
+			title: "include and exclude",
+			task: `
+name: "test {{.Matrix.go}} {{.Matrix.os}}"
+command:
+  command: echo test
+matrix:
+  os: [ubuntu, alpine]
+  go: ["1.15", "1.16"]
+  exclude:
+  - go: "1.15"
+    os: alpine
+  include:
+  - go: "1.17"
+    os: ubuntu
+  # the existing combination isn't added
+  - go: "1.16"
+    os: ubuntu
+`,
+			exp: []string{"test 1.15 ubuntu", "test 1.16 ubuntu", "test 1.16 alpine", "test 1.17 ubuntu"},
+		},
+		{
+			title: "tengo script",
+			task: `
+name: "test {{.Matrix.db}} {{.Matrix.version}}"
+command:
+  command: echo test
+matrix:
+  db: [mysql]
+  version: |
+    result := [5, 8]
+  exclude:
+  - version: 5
+`,
+			exp: []string{"test mysql 8"},
+		},
+		{
+			title: "the axis isn't a list",
+			task: `
+name: test
+command:
+  command: echo test
+matrix:
+  os: |
+    result := "ubuntu"
+`,
+			isErr: true,
+		},
+	}
+	for _, d := range data {
+		d := d
+		t.Run(d.title, func(t *testing.T) {
+			task := config.Task{}
+			if err := yaml.UnmarshalStrict([]byte(d.task), &task); err != nil {
+				t.Fatal(err)
+			}
+			tasks, err := controller.Expand(task, controller.Params{})
+			if d.isErr {
+				assert.NotNil(t, err)
+				return
+			}
+			if !assert.Nil(t, err) {
+				return
+			}
+			names := make([]string, len(tasks))
+			for i, task := range tasks {
+				names[i] = task.Name.Text
+			}
+			assert.Equal(t, d.exp, names)
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package controller
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/joho/godotenv"
+	"github.com/pelletier/go-toml"
+	"github.com/suzuki-shunsuke/go-convmap/convmap"
+	"gopkg.in/ini.v1"
+	"gopkg.in/yaml.v2"
+)
+
+func decodeData(format, text string, csvHeader bool) (interface{}, error) {
+	switch format {
+	case "":
+		return nil, nil
+	case "json":
+		var d interface{}
+		if err := json.Unmarshal([]byte(text), &d); err != nil {
+			return nil, err
+		}
+		return d, nil
+	case "yaml":
+		var d interface{}
+		if err := yaml.Unmarshal([]byte(text), &d); err != nil {
+			return nil, err
+		}
+		return convmap.Convert(d)
+	case "toml":
+		tree, err := toml.Load(text)
+		if err != nil {
+			return nil, err
+		}
+		return tree.ToMap(), nil
+	case "csv":
+		return decodeCSV(text, csvHeader)
+	case "dotenv":
+		m, err := godotenv.Unmarshal(text)
+		if err != nil {
+			return nil, err
+		}
+		d := make(map[string]interface{}, len(m))
+		for k, v := range m {
+			d[k] = v
+		}
+		return d, nil
+	case "ini":
+		return decodeINI(text)
+	default:
+		return nil, errors.New("invalid format: " + format)
+	}
+}
+
+func encodeData(format string, data interface{}) (string, error) {
+	switch format {
+	case "json":
+		b, err := json.MarshalIndent(data, "", "  ")
+		if err != nil {
+			return "", err
+		}
+		return string(b) + "\n", nil
+	case "yaml":
+		b, err := yaml.Marshal(data)
+		if err != nil {
+			return "", err
+		}
+		return string(b), nil
+	case "toml":
+		m, ok := data.(map[string]interface{})
+		if !ok {
+			return "", errors.New("data should be a map")
+		}
+		tree, err := toml.TreeFromMap(m)
+		if err != nil {
+			return "", err
+		}
+		return tree.ToTomlString()
+	default:
+		return "", errors.New("invalid format: " + format)
+	}
+}
+
+func decodeCSV(text string, header bool) (interface{}, error) {
+	records, err := csv.NewReader(strings.NewReader(text)).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if !header {
+		rows := make([]interface{}, len(records))
+		for i, record := range records {
+			row := make([]interface{}, len(record))
+			for j, v := range record {
+				row[j] = v
+			}
+			rows[i] = row
+		}
+		return rows, nil
+	}
+	if len(records) == 0 {
+		return []interface{}{}, nil
+	}
+	keys := records[0]
+	rows := make([]interface{}, len(records)-1)
+	for i, record := range records[1:] {
+		row := make(map[string]interface{}, len(keys))
+		for j, key := range keys {
+			row[key] = record[j]
+		}
+		rows[i] = row
+	}
+	return rows, nil
+}
+
+func decodeINI(text string) (interface{}, error) {
+	f, err := ini.Load([]byte(text))
+	if err != nil {
+		return nil, err
+	}
+	sections := f.Sections()
+	d := make(map[string]interface{}, len(sections))
+	for _, section := range sections {
+		keys := section.Keys()
+		m := make(map[string]interface{}, len(keys))
+		for _, key := range keys {
+			m[key.Name()] = key.Value()
+		}
+		d[section.Name()] = m
+	}
+	return d, nil
+}
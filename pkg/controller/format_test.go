@@ -0,0 +1,152 @@
+package controller_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/suzuki-shunsuke/buildflow/pkg/config"
+	"github.com/suzuki-shunsuke/buildflow/pkg/constant"
+	"github.com/suzuki-shunsuke/buildflow/pkg/controller"
+	"github.com/suzuki-shunsuke/buildflow/pkg/domain"
+	"github.com/suzuki-shunsuke/buildflow/pkg/file"
+)
+
+type fileReader struct {
+	text string
+}
+
+func (reader fileReader) Read(p string) (domain.FileResult, error) {
+	return domain.FileResult{Path: p, Text: reader.text}, nil
+}
+
+func (reader fileReader) List(params file.ParamsList) ([]domain.FileResult, error) {
+	return nil, nil
+}
+
+func (reader fileReader) Glob(params file.ParamsGlob) ([]domain.FileResult, error) {
+	return nil, nil
+}
+
+func TestTask_Run_readFile(t *testing.T) { //nolint:funlen
+	data := []struct {
+		title     string
+		format    string
+		csvHeader bool
+		text      string
+		exp       interface{}
+		isErr     bool
+	}{
+		{
+			title:  "toml",
+			format: "toml",
+			text: `name = "foo"
+[server]
+port = 8080
+`,
+			exp: map[string]interface{}{
+				"name": "foo",
+				"server": map[string]interface{}{
+					"port": int64(8080),
+				},
+			},
+		},
+		{
+			title:     "csv with the header",
+			format:    "csv",
+			csvHeader: true,
+			text: `name,age
+foo,10
+bar,20
+`,
+			exp: []interface{}{
+				map[string]interface{}{"name": "foo", "age": "10"},
+				map[string]interface{}{"name": "bar", "age": "20"},
+			},
+		},
+		{
+			title:     "csv with only the header",
+			format:    "csv",
+			csvHeader: true,
+			text:      "name,age\n",
+			exp:       []interface{}{},
+		},
+		{
+			title:  "csv without the header",
+			format: "csv",
+			text: `name,age
+foo,10
+`,
+			exp: []interface{}{
+				[]interface{}{"name", "age"},
+				[]interface{}{"foo", "10"},
+			},
+		},
+		{
+			title:  "dotenv",
+			format: "dotenv",
+			text: `FOO=foo
+# comment
+BAR="bar baz"
+`,
+			exp: map[string]interface{}{
+				"FOO": "foo",
+				"BAR": "bar baz",
+			},
+		},
+		{
+			title:  "ini",
+			format: "ini",
+			text: `name = foo
+[server]
+port = 8080
+`,
+			exp: map[string]interface{}{
+				"DEFAULT": map[string]interface{}{"name": "foo"},
+				"server":  map[string]interface{}{"port": "8080"},
+			},
+		},
+		{
+			title:  "invalid toml",
+			format: "toml",
+			text:   "name = ",
+			isErr:  true,
+		},
+		{
+			title:  "the number of fields is different",
+			format: "csv",
+			text: `name,age
+foo
+`,
+			isErr: true,
+		},
+	}
+	for _, d := range data {
+		d := d
+		t.Run(d.title, func(t *testing.T) {
+			task := controller.Task{
+				Config: config.Task{
+					Type: constant.ReadFile,
+					ReadFile: config.ReadFile{
+						Path:      config.Template{Text: "/tmp/foo." + d.format},
+						Format:    d.format,
+						CSVHeader: d.csvHeader,
+					},
+				},
+				FileReader: fileReader{text: d.text},
+				Timer:      timer{},
+			}
+			result, err := task.Run(context.Background(), controller.Params{})
+			if d.isErr {
+				if assert.NotNil(t, err) {
+					assert.Contains(t, err.Error(), "/tmp/foo."+d.format)
+				}
+				return
+			}
+			if !assert.Nil(t, err) {
+				return
+			}
+			assert.Equal(t, d.exp, result.File.Data)
+		})
+	}
+}
@@ -6,37 +6,76 @@ import (
 	"time"
 
 	"github.com/google/go-github/v32/github"
+	"github.com/suzuki-shunsuke/buildflow/pkg/cache"
 	"github.com/suzuki-shunsuke/buildflow/pkg/config"
 	"github.com/suzuki-shunsuke/buildflow/pkg/domain"
 	"github.com/suzuki-shunsuke/buildflow/pkg/execute"
+	"github.com/suzuki-shunsuke/buildflow/pkg/file"
+	"github.com/suzuki-shunsuke/buildflow/pkg/git"
 	gh "github.com/suzuki-shunsuke/buildflow/pkg/github"
+	"github.com/suzuki-shunsuke/buildflow/pkg/httpclient"
 )
 
 type Controller struct {
-	GitHub     GitHub
-	Config     config.Config
-	Executor   Executor
-	FileReader FileReader
-	FileWriter FileWriter
-	Timer      Timer
-	Stdout     io.Writer
-	Stderr     io.Writer
+	GitHub       GitHub
+	Config       config.Config
+	Executor     Executor
+	FileReader   FileReader
+	FileWriter   FileWriter
+	HTTPClient   HTTPClient
+	ConfigReader ConfigReader
+	Git          Git
+	// Cache is nil if the cache is disabled
+	Cache  Cache
+	Timer  Timer
+	Stdout io.Writer
+	Stderr io.Writer
+	// ConfigPaths is the absolute paths of the configuration files of the parent builds and the build.
+	// This is used to detect the circular buildflow tasks.
+	ConfigPaths []string
+	// resourcePool limits the number of tasks which are run in parallel across phases
+	resourcePool *ResourcePool
 }
 
 type Executor interface {
 	Run(ctx context.Context, params execute.Params) (domain.CommandResult, error)
 }
 
+type Cache interface {
+	Get(key string) (cache.Entry, bool, error)
+	Restore(key, dir string, entry cache.Entry) error
+	Put(key, dir string, entry cache.Entry) error
+	Evict() error
+}
+
 type Timer interface {
 	Now() time.Time
 }
 
 type FileReader interface {
 	Read(path string) (domain.FileResult, error)
+	List(params file.ParamsList) ([]domain.FileResult, error)
+	Glob(params file.ParamsGlob) ([]domain.FileResult, error)
 }
 
 type FileWriter interface {
-	Write(path, text string) (domain.FileResult, error)
+	Write(params file.ParamsWrite) (domain.FileResult, error)
+}
+
+type HTTPClient interface {
+	Send(ctx context.Context, params httpclient.ParamsSend) (domain.HTTPResult, error)
+}
+
+type Git interface {
+	ChangedFiles(ctx context.Context, params git.ParamsChangedFiles) ([]git.File, error)
+}
+
+type ConfigReader interface {
+	Read(p string) (config.Config, error)
+}
+
+type Builder interface {
+	RunBuild(ctx context.Context, params ParamsRunBuild) (domain.BuildResult, error)
 }
 
 type GitHub interface {
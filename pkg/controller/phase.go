@@ -1,31 +1,39 @@
 package controller
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
 	"io"
+	"net/http"
+	"net/url"
 	"path/filepath"
-	"strings"
-	"sync"
 	"time"
 
 	"github.com/sirupsen/logrus"
 	"github.com/suzuki-shunsuke/buildflow/pkg/config"
 	"github.com/suzuki-shunsuke/buildflow/pkg/constant"
 	"github.com/suzuki-shunsuke/buildflow/pkg/locale"
+	"github.com/suzuki-shunsuke/buildflow/pkg/signal"
 )
 
 type Phase struct {
-	Config     config.Phase
-	EventQueue *EventQueue
-	Stdout     io.Writer
-	Stderr     io.Writer
-	TaskQueue  TaskQueue
-	Status     string
-	Error      error
-	Exit       bool
-	Tasks      *TaskList
+	Config config.Phase
+	Stdout io.Writer
+	Stderr io.Writer
+	// ResourcePool is shared by phases
+	ResourcePool *ResourcePool
+	Status       string
+	Error        error
+	Exit         bool
+	Tasks        *TaskList
+	// cancel cancels the context of running tasks.
+	cancel context.CancelFunc
+	// events receives the index of the task which is finished
+	events chan int
+	// indices is the map from the task name to the indices of tasks
+	indices map[string][]int
 }
 
 func (phase Phase) Name() string {
@@ -36,72 +44,24 @@ func (phase Phase) Meta() map[string]interface{} {
 	return phase.Config.Meta
 }
 
-type EventQueue struct {
-	Queue  chan struct{}
-	mutex  sync.RWMutex
-	closed bool
-}
-
-func (queue *EventQueue) Push() {
-	queue.mutex.Lock()
-	if !queue.closed {
-		queue.Queue <- struct{}{}
-	}
-	queue.mutex.Unlock()
-}
-
-func (queue *EventQueue) Pop() {
-	queue.mutex.Lock()
-	<-queue.Queue
-	queue.mutex.Unlock()
-}
-
-func (queue *EventQueue) Close() {
-	queue.mutex.Lock()
-	if !queue.closed {
-		close(queue.Queue)
-		queue.closed = true
-	}
-	queue.mutex.Unlock()
-}
-
-type TaskQueue struct {
-	queue chan struct{}
-}
-
-func newTaskQueue(size int) TaskQueue {
-	var queue chan struct{}
-	if size > 0 {
-		queue = make(chan struct{}, size)
-	}
-	return TaskQueue{
-		queue: queue,
-	}
-}
-
-func (queue *TaskQueue) push() {
-	if queue.queue != nil {
-		queue.queue <- struct{}{}
-	}
-}
-
-func (queue *TaskQueue) pop() {
-	if queue.queue != nil {
-		<-queue.queue
-	}
-}
-
 func (phase *Phase) Get(name string) []Task {
-	arr := []Task{}
-	for _, task := range phase.Tasks.GetAll() {
-		if task.Name() == name {
-			arr = append(arr, task)
-		}
+	indices := phase.indices[name]
+	arr := make([]Task, len(indices))
+	for i, idx := range indices {
+		arr[i] = phase.Tasks.Get(idx)
 	}
 	return arr
 }
 
+// outputResult outputs the result of the phase.
+// The result is written at once so that it isn't interleaved with the outputs of other phases.
 func (phase Phase) outputResult(stderr io.Writer, name string) {
+	buf := &bytes.Buffer{}
+	phase.writeResult(buf, name)
+	stderr.Write(buf.Bytes()) //nolint:errcheck
+}
+
+func (phase Phase) writeResult(stderr io.Writer, name string) {
 	fmt.Fprintln(stderr, "\n================")
 	fmt.Fprintln(stderr, "= Phase Result: "+name+" =")
 	fmt.Fprintln(stderr, "================")
@@ -109,7 +69,7 @@ func (phase Phase) outputResult(stderr io.Writer, name string) {
 	if phase.Error != nil {
 		fmt.Fprintln(stderr, "error:", phase.Error)
 	}
-	if phase.Status == constant.Skipped {
+	if phase.Status == constant.Skipped || phase.Tasks == nil {
 		return
 	}
 	utc := locale.UTC()
@@ -125,8 +85,34 @@ func (phase Phase) outputResult(stderr io.Writer, name string) {
 	}
 	for _, task := range runTasks {
 		fmt.Fprintln(stderr, "task:", task.Name())
-		fmt.Fprintln(stderr, "status:", task.Result.Status)
-		fmt.Fprintln(stderr, "exit code:", task.Result.Command.ExitCode)
+		switch task.Result.Status {
+		case constant.Warning:
+			fmt.Fprintln(stderr, "status:", task.Result.Status, "(the task failed but the failure is allowed)")
+		case constant.Cancelled:
+			fmt.Fprintln(stderr, "status:", task.Result.Status, "(the task is cancelled because another task failed or the build is interrupted)")
+		case constant.Cached:
+			fmt.Fprintln(stderr, "status:", task.Result.Status, "(the task isn't run because the result is restored from the cache)")
+		case constant.TimedOut:
+			fmt.Fprintln(stderr, "status:", task.Result.Status, "(the task is cancelled because the timeout of the phase or the build expired)")
+		case constant.UpstreamFailed:
+			fmt.Fprintln(stderr, "status:", task.Result.Status, "(the task isn't run because the dependency failed)")
+			continue
+		default:
+			fmt.Fprintln(stderr, "status:", task.Result.Status)
+		}
+		// the error of the command task is empty if the exit code isn't zero
+		if task.Result.Error != nil && task.Result.Error.Error() != "" {
+			fmt.Fprintln(stderr, "error:", task.Result.Error)
+		}
+		if task.Result.Time.Start.IsZero() {
+			// the task isn't started, e.g. the queued task is cancelled
+			continue
+		}
+		if task.Config.Type == constant.HTTP {
+			fmt.Fprintln(stderr, "http status:", task.Result.HTTP.Status)
+		} else {
+			fmt.Fprintln(stderr, "exit code:", task.Result.Command.ExitCode)
+		}
 		fmt.Fprintln(stderr, "start time:", task.Result.Time.Start.In(utc).Format(time.RFC3339))
 		fmt.Fprintln(stderr, "end time:", task.Result.Time.End.In(utc).Format(time.RFC3339))
 		fmt.Fprintln(stderr, "duration:", task.Result.Time.End.Sub(task.Result.Time.Start))
@@ -147,6 +133,9 @@ func (phase *Phase) IsReady(task Task, params Params) (bool, error) {
 			}
 		}
 	}
+	if task.Config.Dependency.Program.Empty() {
+		return true, nil
+	}
 	b, err := task.Config.Dependency.Program.Match(params.ToExpr())
 	if err != nil {
 		task.Result.Status = constant.Failed
@@ -158,36 +147,188 @@ func (phase *Phase) IsReady(task Task, params Params) (bool, error) {
 	return true, nil
 }
 
-func (phase *Phase) PrepareCommandTask(task Task, params Params, wd string) (Task, error) {
-	cmd, err := task.Config.Command.Command.New(params.ToTemplate())
+// runsAfterCancel returns true if the queued task is run even if the phase is cancelled.
+// `if: always` tasks and `if: failure` tasks whose dependencies failed are run.
+func (phase *Phase) runsAfterCancel(task Task, params Params) bool {
+	switch task.Config.If {
+	case constant.IfAlways:
+		return true
+	case constant.IfFailure:
+		if isReady, err := phase.IsReady(task, params); err != nil || !isReady {
+			return false
+		}
+		return phase.checkDependencyResult(task) == ""
+	default:
+		return false
+	}
+}
+
+// checkDependencyResult returns the status of the task which isn't run because of the dependencies' results.
+// If the task should be run, an empty string is returned.
+func (phase *Phase) checkDependencyResult(task Task) string {
+	failed := false
+	for _, dependOn := range task.Config.Dependency.Names {
+		for _, dependency := range phase.Get(dependOn) {
+			if dependency.Result.IsFailed() {
+				failed = true
+			}
+		}
+	}
+	switch task.Config.If {
+	case constant.IfAlways:
+		return ""
+	case constant.IfFailure:
+		if failed {
+			return ""
+		}
+		return constant.Skipped
+	default:
+		if failed {
+			return constant.UpstreamFailed
+		}
+		return ""
+	}
+}
+
+func (phase *Phase) PrepareCommandTask(task Task, tplParams map[string]interface{}, wd string) (Task, error) {
+	cmd, err := task.Config.Command.Command.New(tplParams)
 	if err != nil {
 		task.Result.Status = constant.Failed
 		return task, fmt.Errorf(`failed to render a command: %w`, err)
 	}
 	task.Config.Command.Command = cmd
 
-	stdin, err := task.Config.Command.Stdin.New(params.ToTemplate())
+	stdin, err := task.Config.Command.Stdin.New(tplParams)
 	if err != nil {
 		task.Result.Status = constant.Failed
 		return task, fmt.Errorf(`failed to render a command.stdin: %w`, err)
 	}
 	task.Config.Command.Stdin = stdin
 
-	m, err := renderEnvs(task.Config.Command.Env, params)
+	m, err := renderEnvs(task.Config.Command.Env, tplParams)
 	if err != nil {
 		task.Result.Status = constant.Failed
 		return task, err
 	}
 	task.Config.Command.Env.Compiled = m
+
+	for _, tpls := range []*[]config.Template{&task.Config.Cache.Sources, &task.Config.Cache.Outputs, &task.Config.Cache.Keys} {
+		arr, err := renderTemplates(*tpls, tplParams)
+		if err != nil {
+			task.Result.Status = constant.Failed
+			return task, fmt.Errorf(`failed to render cache: %w`, err)
+		}
+		*tpls = arr
+	}
+	return task, nil
+}
+
+func (phase *Phase) PrepareHTTPTask(task Task, tplParams map[string]interface{}) (Task, error) {
+	method, err := task.Config.HTTP.Method.New(tplParams)
+	if err != nil {
+		task.Result.Status = constant.Failed
+		return task, fmt.Errorf(`failed to render http.method: %w`, err)
+	}
+	task.Config.HTTP.Method = method
+
+	u, err := task.Config.HTTP.URL.New(tplParams)
+	if err != nil {
+		task.Result.Status = constant.Failed
+		return task, fmt.Errorf(`failed to render http.url: %w`, err)
+	}
+	task.Config.HTTP.URL = u
+
+	body, err := task.Config.HTTP.Body.New(tplParams)
+	if err != nil {
+		task.Result.Status = constant.Failed
+		return task, fmt.Errorf(`failed to render http.body: %w`, err)
+	}
+	task.Config.HTTP.Body = body
+
+	header := make(http.Header, len(task.Config.HTTP.Header))
+	for k, v := range task.Config.HTTP.Header {
+		val, err := v.Template.Render(tplParams)
+		if err != nil {
+			task.Result.Status = constant.Failed
+			return task, fmt.Errorf(`failed to render http.header %s: %w`, k, err)
+		}
+		header.Add(k, val)
+	}
+	task.Config.HTTP.CompiledHeader = header
+
+	query := make(url.Values, len(task.Config.HTTP.Query))
+	for k, v := range task.Config.HTTP.Query {
+		val, err := v.Template.Render(tplParams)
+		if err != nil {
+			task.Result.Status = constant.Failed
+			return task, fmt.Errorf(`failed to render http.query %s: %w`, k, err)
+		}
+		query.Add(k, val)
+	}
+	task.Config.HTTP.CompiledQuery = query
+	return task, nil
+}
+
+func renderTemplates(tpls []config.Template, tplParams map[string]interface{}) ([]config.Template, error) {
+	arr := make([]config.Template, len(tpls))
+	for i, tpl := range tpls {
+		t, err := tpl.New(tplParams)
+		if err != nil {
+			return nil, err
+		}
+		arr[i] = t
+	}
+	return arr, nil
+}
+
+func (phase *Phase) PrepareGlobTask(task Task, tplParams map[string]interface{}, wd string) (Task, error) {
+	root, err := task.Config.Glob.Root.New(tplParams)
+	if err != nil {
+		task.Result.Status = constant.Failed
+		return task, fmt.Errorf(`failed to render glob.root: %w`, err)
+	}
+	if !filepath.IsAbs(root.Text) {
+		root.Text = filepath.Join(wd, root.Text)
+	}
+	task.Config.Glob.Root = root
+
+	include, err := renderTemplates(task.Config.Glob.Include, tplParams)
+	if err != nil {
+		task.Result.Status = constant.Failed
+		return task, fmt.Errorf(`failed to render glob.include: %w`, err)
+	}
+	task.Config.Glob.Include = include
+
+	exclude, err := renderTemplates(task.Config.Glob.Exclude, tplParams)
+	if err != nil {
+		task.Result.Status = constant.Failed
+		return task, fmt.Errorf(`failed to render glob.exclude: %w`, err)
+	}
+	task.Config.Glob.Exclude = exclude
 	return task, nil
 }
 
+// PrepareTask renders the task.
+// The relative paths of the task are resolved relative to the task's working directory.
+// The template parameters are built only once because it costs proportional to the number of tasks.
 func (phase *Phase) PrepareTask(task Task, params Params, wd string) (Task, error) {
+	tplParams := params.ToTemplate()
+	dir, err := task.Config.Dir.New(tplParams)
+	if err != nil {
+		task.Result.Status = constant.Failed
+		return task, fmt.Errorf(`failed to render dir: %w`, err)
+	}
+	if !filepath.IsAbs(dir.Text) {
+		dir.Text = filepath.Join(wd, dir.Text)
+	}
+	task.Config.Dir = dir
+	wd = dir.Text
+
 	switch task.Config.Type {
 	case constant.Command:
-		return phase.PrepareCommandTask(task, params, wd)
+		return phase.PrepareCommandTask(task, tplParams, wd)
 	case constant.ReadFile:
-		p, err := task.Config.ReadFile.Path.New(params.ToTemplate())
+		p, err := task.Config.ReadFile.Path.New(tplParams)
 		if err != nil {
 			task.Result.Status = constant.Failed
 			return task, fmt.Errorf(`failed to render read_file.path: %w`, err)
@@ -198,7 +339,7 @@ func (phase *Phase) PrepareTask(task Task, params Params, wd string) (Task, erro
 		}
 		return task, nil
 	case constant.WriteFile:
-		p, err := task.Config.WriteFile.Path.New(params.ToTemplate())
+		p, err := task.Config.WriteFile.Path.New(tplParams)
 		if err != nil {
 			task.Result.Status = constant.Failed
 			return task, fmt.Errorf(`failed to render write_file.path: %w`, err)
@@ -207,137 +348,279 @@ func (phase *Phase) PrepareTask(task Task, params Params, wd string) (Task, erro
 		if !filepath.IsAbs(p.Text) {
 			task.Config.WriteFile.Path.Text = filepath.Join(wd, p.Text)
 		}
-		tpl, err := task.Config.WriteFile.Template.New(params.ToTemplate())
+		if task.Config.WriteFile.Format != "" {
+			data, err := task.Config.WriteFile.Data.Run(params.ToExpr())
+			if err != nil {
+				task.Result.Status = constant.Failed
+				return task, fmt.Errorf(`failed to evaluate write_file.data: %w`, err)
+			}
+			content, err := encodeData(task.Config.WriteFile.Format, data)
+			if err != nil {
+				task.Result.Status = constant.Failed
+				return task, fmt.Errorf(`failed to encode write_file.data as %s: %w`, task.Config.WriteFile.Format, err)
+			}
+			task.Config.WriteFile.Content = content
+			return task, nil
+		}
+		tpl, err := task.Config.WriteFile.Template.New(tplParams)
 		if err != nil {
 			task.Result.Status = constant.Failed
 			return task, fmt.Errorf(`failed to render write_file.template: %w`, err)
 		}
 		task.Config.WriteFile.Template = tpl
+		// a new line is appended to the end of the file
+		task.Config.WriteFile.Content = tpl.Text + "\n"
+		return task, nil
+	case constant.Glob:
+		return phase.PrepareGlobTask(task, tplParams, wd)
+	case constant.HTTP:
+		return phase.PrepareHTTPTask(task, tplParams)
+	case constant.Script:
+		return task, nil
+	case constant.Buildflow:
+		p, err := task.Config.Buildflow.Config.New(tplParams)
+		if err != nil {
+			task.Result.Status = constant.Failed
+			return task, fmt.Errorf(`failed to render buildflow.config: %w`, err)
+		}
+		task.Config.Buildflow.Config = p
+		if !filepath.IsAbs(p.Text) {
+			task.Config.Buildflow.Config.Text = filepath.Join(wd, p.Text)
+		}
 		return task, nil
 	default:
 		return task, errors.New("invalid task type")
 	}
 }
 
-func (phase *Phase) runTask(ctx context.Context, idx int, task Task, params Params, paramsPhase Phase, wd string) {
+// fail sets the status of the failed task.
+// If the failure is allowed, the status is "warning".
+func (phase *Phase) fail(idx int, task Task, params Params, paramsPhase Phase, err error) Task {
+	logE := logrus.WithFields(logrus.Fields{
+		"phase_name": phase.Config.Name,
+		"task_name":  task.Name(),
+		"task_index": idx,
+	})
+	task.Result.Error = err
+	// the status is kept running until the condition is evaluated
+	task.Result.Status = constant.Running
+	paramsPhase.Tasks.Set(idx, task)
+	f, e := matchBool(task.Config.AllowFailure, params)
+	if e != nil {
+		logE.WithError(e).Error("failed to evaluate allow_failure")
+	}
+	if f && e == nil {
+		task.Result.Status = constant.Warning
+		logE.WithError(err).Warn("the task failed but the failure is allowed")
+		return task
+	}
+	task.Result.Status = constant.Failed
+	return task
+}
+
+func (phase *Phase) runTask(ctx context.Context, idx int, task Task, rawCfg config.Task, params Params, paramsPhase Phase, wd string) {
 	defer func() {
 		paramsPhase.Tasks.Set(idx, task)
-		phase.EventQueue.Push()
+		phase.events <- idx
 	}()
-	phase.TaskQueue.push()
-	result, err := task.Run(ctx, wd)
-	phase.TaskQueue.pop()
-	task.Result = result
+	key, cached := phase.restoreCache(idx, &task)
+	if cached {
+		task.Result.Status = constant.Cached
+	} else {
+		t, err := phase.runTaskWithRetry(ctx, idx, task, rawCfg, params, paramsPhase, wd)
+		task = t
+		if err != nil && ctx.Err() != nil {
+			task.Result.Status = cancelledStatus(ctx)
+			logrus.WithFields(logrus.Fields{
+				"phase_name": phase.Config.Name,
+				"task_name":  task.Name(),
+				"task_index": idx,
+				"status":     task.Result.Status,
+			}).WithError(err).Warn("the task is cancelled")
+			return
+		}
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"phase_name": phase.Config.Name,
+				"task_name":  task.Name(),
+				"task_index": idx,
+			}).WithError(err).Error("failed to run a task")
+			task = phase.fail(idx, task, params, paramsPhase, err)
+			return
+		}
+		task.Result.Status = constant.Succeeded
+		phase.saveCache(idx, task, key)
+	}
+	if task.Config.Output.Prog.Empty() {
+		return
+	}
+	paramsPhase.Tasks.Set(idx, task)
+	output, err := task.Config.Output.Run(params.ToExpr())
 	if err != nil {
-		task.Result.Status = constant.Failed
-		task.Result.Error = err
 		logrus.WithFields(logrus.Fields{
 			"phase_name": phase.Config.Name,
 			"task_name":  task.Name(),
 			"task_index": idx,
-		}).WithError(err).Error("failed to run a task")
+		}).WithError(err).Error("failed to run an output")
+		task = phase.fail(idx, task, params, paramsPhase, err)
 		return
 	}
-	task.Result.Status = constant.Succeeded
-	paramsPhase.Tasks.Set(idx, task)
-	output, err := task.Config.Output.Run(params.ToExpr())
+	if output != nil {
+		task.Result.Output = output
+	}
+}
+
+// restoreCache restores the result of the task from the cache and returns the cache key.
+// If the cache isn't used, the key is empty.
+// The error of the cache doesn't make the task fail but the task is run.
+func (phase *Phase) restoreCache(idx int, task *Task) (string, bool) {
+	logE := logrus.WithFields(logrus.Fields{
+		"phase_name": phase.Config.Name,
+		"task_name":  task.Name(),
+		"task_index": idx,
+	})
+	key, err := task.cacheKey()
 	if err != nil {
-		task.Result.Status = constant.Failed
-		task.Result.Error = err
+		logE.WithError(err).Warn("failed to compute the cache key. The cache isn't used")
+		return "", false
+	}
+	if key == "" {
+		return "", false
+	}
+	t, found, err := task.restoreCache(key)
+	if err != nil {
+		logE.WithError(err).Warn("failed to restore the cache. The task is run")
+		return key, false
+	}
+	if found {
+		logE.Info("the result is restored from the cache")
+		*task = t
+	}
+	return key, found
+}
+
+func (phase *Phase) saveCache(idx int, task Task, key string) {
+	if key == "" {
+		return
+	}
+	if err := task.saveCache(key); err != nil {
 		logrus.WithFields(logrus.Fields{
 			"phase_name": phase.Config.Name,
 			"task_name":  task.Name(),
 			"task_index": idx,
-		}).WithError(err).Error("failed to run an output")
-		return
+		}).WithError(err).Warn("failed to store the cache")
 	}
-	task.Result.Output = output
 }
 
-func (phase *Phase) RunTask(ctx context.Context, idx int, task Task, params Params, wd string) error {
+// RunTask starts the task if the task is ready.
+// If the task is started, true is returned and the index of the task is sent to phase.events when the task is finished.
+// Otherwise, the task is either finished without running or kept queued.
+func (phase *Phase) RunTask(ctx context.Context, idx int, task Task, params Params, wd string) (started bool, err error) { //nolint:nonamedreturns
 	if task.Result.Status != constant.Queue {
-		return nil
+		return false, nil
 	}
 	params.TaskIdx = idx
 
 	params.Item = task.Config.Item
+	params.Matrix = task.Config.Combination
 	paramsPhase := params.Phases[params.PhaseName]
 	defer func() {
+		// after the task is started, the result is updated by runTask
+		if started {
+			return
+		}
+		if err != nil && (task.Result.Status == constant.Queue || task.Result.Status == constant.Running) {
+			task.Result.Status = constant.Failed
+		}
 		paramsPhase.Tasks.Set(idx, task)
 	}()
 
+	if ctx.Err() != nil {
+		detached := signal.Detach(ctx)
+		if detached.Err() != nil || !phase.runsAfterCancel(task, params) {
+			// the queued task isn't started after the phase is cancelled
+			task.Result.Status = cancelledStatus(ctx)
+			return false, nil
+		}
+		// the cleanup task and the task which handles the failure are run even if the phase is cancelled.
+		// The task is still cancelled by its own timeout and the second signal.
+		ctx = detached
+	}
+
 	if isReady, err := phase.IsReady(task, params); err != nil || !isReady {
-		return err
+		return false, err
+	}
+
+	if status := phase.checkDependencyResult(task); status != "" {
+		task.Result.Status = status
+		return false, nil
 	}
 
-	f, err := task.Config.When.Match(params.ToExpr())
+	f, err := matchBool(task.Config.When, params)
 	if err != nil {
 		task.Result.Status = constant.Failed
-		return fmt.Errorf(`failed to evaluate task's "when": %w`, err)
+		return false, fmt.Errorf(`failed to evaluate task's "when": %w`, err)
 	}
 	if !f {
 		task.Result.Status = constant.Skipped
-		return nil
+		return false, nil
 	}
 
 	task.Result.Status = constant.Running
 	paramsPhase.Tasks.Set(idx, task)
 
 	// evaluate input and add params
-	input, err := task.Config.Input.Run(params.ToExpr())
-	if err != nil {
-		task.Result.Status = constant.Failed
-		task.Result.Error = err
-		logrus.WithFields(logrus.Fields{
-			"phase_name": phase.Config.Name,
-			"task_name":  task.Name(),
-			"task_index": idx,
-		}).WithError(err).Error("failed to run an input")
-		return fmt.Errorf(`failed to run an input: %w`, err)
+	if !task.Config.Input.Prog.Empty() {
+		input, err := task.Config.Input.Run(params.ToExpr())
+		if err != nil {
+			task.Result.Status = constant.Failed
+			task.Result.Error = err
+			logrus.WithFields(logrus.Fields{
+				"phase_name": phase.Config.Name,
+				"task_name":  task.Name(),
+				"task_index": idx,
+			}).WithError(err).Error("failed to run an input")
+			return false, fmt.Errorf(`failed to run an input: %w`, err)
+		}
+		task.Result.Input = input
+		paramsPhase.Tasks.Set(idx, task)
 	}
-	task.Result.Input = input
-	paramsPhase.Tasks.Set(idx, task)
 
+	rawCfg := task.Config
 	task, err = phase.PrepareTask(task, params, wd)
 	if err != nil {
-		return err
+		return false, err
 	}
 
-	go phase.runTask(ctx, idx, task, params, paramsPhase, wd)
-	return nil
+	paramsPhase.Tasks.Set(idx, task)
+	go phase.runTask(ctx, idx, task, rawCfg, params, paramsPhase, wd)
+	return true, nil
 }
 
-func (phase *Phase) Run(ctx context.Context, params Params, wd string) error {
-	p := params.Phases[params.PhaseName]
-	for i, task := range p.Tasks.GetAll() {
-		if err := phase.RunTask(ctx, i, task, params, wd); err != nil {
-			logrus.WithFields(logrus.Fields{
-				"task_name":  task.Name(),
-				"phase_name": phase.Config.Name,
-			}).WithError(err).Error("failed to run a task")
-		}
+// cancelledStatus returns the status of the task which is cancelled.
+// If the timeout of the phase or the build expired, the status is "timed_out".
+func cancelledStatus(ctx context.Context) string {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return constant.TimedOut
 	}
-	allFinished := true
-	noRunning := true
-	queuedTasks := []string{}
-	for _, task := range p.Tasks.GetAll() {
-		if !task.Result.IsFinished() {
-			allFinished = false
-			if task.Result.Status == constant.Running {
-				noRunning = false
-				break
-			}
-			if task.Result.Status == constant.Queue {
-				queuedTasks = append(queuedTasks, task.Name())
-			}
+	return constant.Cancelled
+}
+
+func (phase *Phase) hasCancelledTask() bool {
+	for _, task := range phase.Tasks.GetAll() {
+		if task.Result.Status == constant.Cancelled {
+			return true
 		}
 	}
-	if allFinished {
-		phase.EventQueue.Close()
-		return nil
+	return false
+}
+
+// failFast cancels running tasks if fail_fast is enabled and the task failed.
+func (phase *Phase) failFast(task Task) {
+	if phase.cancel == nil || phase.Config.FailFast == nil || !*phase.Config.FailFast {
+		return
 	}
-	if noRunning {
-		return errors.New("the phase isn't finished but no task running. Plase check the task dependency is wrong. queued tasks: " + strings.Join(queuedTasks, ", "))
+	if task.Result.Status == constant.Failed {
+		phase.cancel()
 	}
-	return nil
 }
@@ -0,0 +1,133 @@
+package controller
+
+import (
+	"context"
+	"sync"
+
+	"github.com/suzuki-shunsuke/buildflow/pkg/config"
+)
+
+const (
+	// the named resource pool which is defined in the configuration
+	resourceKindPool = "pool"
+	// the build's parallelism
+	resourceKindBuild = "build"
+	// the phase's parallelism
+	resourceKindPhase = "phase"
+)
+
+type resourceKey struct {
+	kind string
+	name string
+}
+
+// ResourcePool manages the named resource pools and the parallelism of the build and phases.
+// All of them are shared by tasks across phases.
+type ResourcePool struct {
+	mutex    sync.Mutex
+	capacity map[resourceKey]int
+	used     map[resourceKey]int
+	// changed is closed and replaced when resources are released
+	changed chan struct{}
+}
+
+// newResourcePool creates the pool from the configuration.
+// If the parallelism is 0, the number of tasks isn't limited.
+func newResourcePool(cfg config.Config) *ResourcePool {
+	capacity := make(map[resourceKey]int, len(cfg.Resources)+len(cfg.Phases)+1)
+	for name, n := range cfg.Resources {
+		capacity[resourceKey{kind: resourceKindPool, name: name}] = n
+	}
+	if cfg.Parallelism > 0 {
+		capacity[resourceKey{kind: resourceKindBuild}] = int(cfg.Parallelism)
+	}
+	for _, phase := range cfg.Phases {
+		if phase.Parallelism > 0 {
+			capacity[resourceKey{kind: resourceKindPhase, name: phase.Name}] = int(phase.Parallelism)
+		}
+	}
+	return &ResourcePool{
+		capacity: capacity,
+		used:     make(map[resourceKey]int, len(capacity)),
+		changed:  make(chan struct{}),
+	}
+}
+
+// request returns the resources which the task uses.
+// The weight of the task is capped by the parallelism, so the heavy task is run alone.
+func (pool *ResourcePool) request(phaseName string, task config.Task) map[resourceKey]int {
+	if pool == nil {
+		return nil
+	}
+	req := make(map[resourceKey]int, len(task.Resources)+2) //nolint:gomnd
+	for name, n := range task.Resources {
+		req[resourceKey{kind: resourceKindPool, name: name}] = n
+	}
+	weight := task.Weight
+	if weight <= 0 {
+		weight = 1
+	}
+	for _, key := range []resourceKey{{kind: resourceKindBuild}, {kind: resourceKindPhase, name: phaseName}} {
+		capacity, ok := pool.capacity[key]
+		if !ok {
+			continue
+		}
+		if weight > capacity {
+			req[key] = capacity
+			continue
+		}
+		req[key] = weight
+	}
+	return req
+}
+
+// tryAcquire acquires all requested units if they are free.
+// If any of them isn't free, nothing is acquired and the channel which is closed when resources are released is returned.
+func (pool *ResourcePool) tryAcquire(req map[resourceKey]int) (bool, <-chan struct{}) {
+	pool.mutex.Lock()
+	defer pool.mutex.Unlock()
+	for key, n := range req {
+		if pool.used[key]+n > pool.capacity[key] {
+			return false, pool.changed
+		}
+	}
+	for key, n := range req {
+		pool.used[key] += n
+	}
+	return true, nil
+}
+
+// acquire waits until all requested units are free and acquires them.
+// Units are acquired at once to avoid the deadlock.
+func (pool *ResourcePool) acquire(ctx context.Context, req map[resourceKey]int) error {
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if pool == nil || len(req) == 0 {
+			return nil
+		}
+		ok, changed := pool.tryAcquire(req)
+		if ok {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-changed:
+		}
+	}
+}
+
+func (pool *ResourcePool) release(req map[resourceKey]int) {
+	if pool == nil || len(req) == 0 {
+		return
+	}
+	pool.mutex.Lock()
+	defer pool.mutex.Unlock()
+	for key, n := range req {
+		pool.used[key] -= n
+	}
+	close(pool.changed)
+	pool.changed = make(chan struct{})
+}
@@ -0,0 +1,122 @@
+package controller
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/suzuki-shunsuke/buildflow/pkg/config"
+	"github.com/suzuki-shunsuke/buildflow/pkg/constant"
+	"github.com/suzuki-shunsuke/buildflow/pkg/domain"
+)
+
+// getRetryDelay returns the delay before the next attempt.
+// attempt is the number of the failed attempt, which starts from 1.
+func getRetryDelay(retry config.Retry, attempt int) time.Duration {
+	delay := retry.Delay
+	if retry.Backoff == constant.BackoffExponential {
+		for i := 1; i < attempt; i++ {
+			delay *= 2
+			if retry.MaxDelay > 0 && delay >= retry.MaxDelay {
+				break
+			}
+		}
+	}
+	if retry.MaxDelay > 0 && delay > retry.MaxDelay {
+		delay = retry.MaxDelay
+	}
+	if retry.Jitter && delay > 0 {
+		// the delay is randomized between the half and the whole
+		half := delay / 2 //nolint:gomnd
+
+		delay = half + time.Duration(rand.Int63n(int64(delay-half)+1)) //nolint:gosec
+	}
+	return delay
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// shouldRetry returns true if the failed task should be run again.
+// The task has the result of the last attempt.
+func (phase *Phase) shouldRetry(ctx context.Context, idx int, task Task, params Params, paramsPhase Phase) bool {
+	retry := task.Config.Retry
+	attempt := len(task.Result.Attempts) + 1
+	if attempt >= retry.MaxAttempts {
+		return false
+	}
+	logE := logrus.WithFields(logrus.Fields{
+		"phase_name": phase.Config.Name,
+		"task_name":  task.Name(),
+		"task_index": idx,
+		"attempt":    attempt,
+	})
+	paramsPhase.Tasks.Set(idx, task)
+	f, err := retry.When.Match(params.ToExpr())
+	if err != nil {
+		logE.WithError(err).Error(`failed to evaluate the retry condition`)
+		return false
+	}
+	if !f {
+		return false
+	}
+	delay := getRetryDelay(retry, attempt)
+	logE.WithField("delay", delay).Warn("retry the task")
+	if err := sleep(ctx, delay); err != nil {
+		logE.WithError(err).Error("the retry is cancelled")
+		return false
+	}
+	return true
+}
+
+// runTaskWithRetry runs the task until the task succeeds or the retry condition isn't satisfied.
+// Before the retry, the task is rendered from rawCfg again so that the results of the previous attempts can be referred.
+func (phase *Phase) runTaskWithRetry(ctx context.Context, idx int, task Task, rawCfg config.Task, params Params, paramsPhase Phase, wd string) (Task, error) {
+	input := task.Result.Input
+	attempts := []domain.Result{}
+	for {
+		req := phase.ResourcePool.request(phase.Name(), task.Config)
+		if err := phase.ResourcePool.acquire(ctx, req); err != nil {
+			// the phase is cancelled while the task waits for resources
+			return task, err
+		}
+		result, err := task.Run(ctx, params)
+		phase.ResourcePool.release(req)
+		result.Input = input
+		result.Attempts = attempts
+		result.Error = err
+		// the status is kept running until the task is finished
+		result.Status = constant.Running
+		task.Result = result
+		if err == nil {
+			return task, nil
+		}
+		if !phase.shouldRetry(ctx, idx, task, params, paramsPhase) {
+			return task, err
+		}
+		result.Status = constant.Failed
+		result.Attempts = nil
+		attempts = append(attempts, result)
+
+		task.Config = rawCfg
+		task.Result.Attempts = attempts
+		paramsPhase.Tasks.Set(idx, task)
+		t, err := phase.PrepareTask(task, params, wd)
+		if err != nil {
+			return t, err
+		}
+		task = t
+	}
+}
@@ -0,0 +1,295 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/suzuki-shunsuke/buildflow/pkg/constant"
+	"github.com/suzuki-shunsuke/buildflow/pkg/execute"
+)
+
+// scheduler runs tasks of the phase when their dependencies are finished.
+// Each task has the counter of unfinished dependencies,
+// and completing a task decrements only the counters of its dependents.
+// The task whose dependency is a tengo script can't be scheduled by the counter,
+// so the script is evaluated every time a task is finished.
+// The lazy task is expanded when its dependencies are finished,
+// and the expanded tasks are added to the phase.
+type scheduler struct {
+	phase  *Phase
+	params Params
+	wd     string
+	// dependents[i] is the indices of tasks which depend on the task i
+	dependents [][]int
+	// pending[i] is the number of unfinished dependencies of the task i
+	pending []int
+	// dynamic is the indices of queued tasks whose dependency is a tengo script
+	dynamic []int
+	// ready is the indices of tasks whose dependencies are finished
+	ready    []int
+	running  int
+	finished int
+	// lazy is the number of lazy tasks which aren't expanded yet
+	lazy int
+}
+
+func newScheduler(phase *Phase, params Params, wd string) *scheduler {
+	tasks := phase.Tasks.GetAll()
+	sched := &scheduler{
+		phase:      phase,
+		params:     params,
+		wd:         wd,
+		dependents: make([][]int, len(tasks)),
+		pending:    make([]int, len(tasks)),
+		ready:      make([]int, 0, len(tasks)),
+	}
+	for _, task := range tasks {
+		if task.lazy && !task.Result.IsFinished() {
+			sched.lazy++
+		}
+	}
+	for i, task := range tasks {
+		if task.Result.IsFinished() {
+			// the task was finished by the previous run such as the run before finally tasks
+			sched.finished++
+			continue
+		}
+		if !task.Config.Dependency.Program.Empty() || sched.waitsExpansion(task) {
+			sched.dynamic = append(sched.dynamic, i)
+			continue
+		}
+		for _, name := range task.Config.Dependency.Names {
+			for _, idx := range phase.indices[name] {
+				if tasks[idx].Result.IsFinished() {
+					continue
+				}
+				sched.dependents[idx] = append(sched.dependents[idx], i)
+				sched.pending[i]++
+			}
+		}
+		if sched.pending[i] == 0 {
+			sched.ready = append(sched.ready, i)
+		}
+	}
+	return sched
+}
+
+// waitsExpansion returns true if the task depends on the name which isn't found
+// while lazy tasks aren't expanded yet.
+// The name may be the name of a task which is added by the expansion.
+func (sched *scheduler) waitsExpansion(task Task) bool {
+	if sched.lazy == 0 {
+		return false
+	}
+	for _, name := range task.Config.Dependency.Names {
+		if _, ok := sched.phase.indices[name]; !ok {
+			return true
+		}
+	}
+	return false
+}
+
+// expand expands the lazy task if its dependencies are finished.
+// The task is replaced with the first expanded task and the others are appended to the phase.
+// Tasks which depend on the lazy task wait for all expanded tasks.
+// If the task isn't ready or its dependencies failed, expand does nothing and RunTask handles the task.
+func (sched *scheduler) expand(ctx context.Context, idx int) error {
+	phase := sched.phase
+	task := phase.Tasks.Get(idx)
+	params := sched.params
+	params.TaskIdx = idx
+	if ctx.Err() != nil && !phase.runsAfterCancel(task, params) {
+		return nil
+	}
+	if isReady, err := phase.IsReady(task, params); err != nil || !isReady {
+		return nil
+	}
+	if status := phase.checkDependencyResult(task); status != "" {
+		return nil
+	}
+
+	sched.lazy--
+	task.lazy = false
+	cfgs, err := Expand(task.Config, params)
+	if err != nil {
+		task.Result.Status = constant.Failed
+		task.Result.Error = err
+		phase.Tasks.Set(idx, task)
+		return fmt.Errorf("failed to expand the task: %w", err)
+	}
+	if len(cfgs) == 0 {
+		task.Result.Status = constant.Skipped
+		phase.Tasks.Set(idx, task)
+		return nil
+	}
+
+	tasks := make([]Task, len(cfgs))
+	for i, cfg := range cfgs {
+		t := task
+		t.Config = cfg
+		t.Stdout = execute.NewWriter(phase.Stdout, cfg.Name.Text)
+		t.Stderr = execute.NewWriter(phase.Stderr, cfg.Name.Text)
+		tasks[i] = t
+	}
+	phase.Tasks.Set(idx, tasks[0])
+	first := phase.Tasks.Append(tasks[1:]...)
+
+	rawName := task.Name()
+	for i, t := range tasks {
+		j := idx
+		if i != 0 {
+			j = first + i - 1
+			// the task which depends on the lazy task waits for all expanded tasks
+			phase.indices[rawName] = append(phase.indices[rawName], j)
+			dependents := make([]int, len(sched.dependents[idx]))
+			copy(dependents, sched.dependents[idx])
+			for _, dependent := range dependents {
+				sched.pending[dependent]++
+			}
+			sched.dependents = append(sched.dependents, dependents)
+			sched.pending = append(sched.pending, 0)
+			sched.ready = append(sched.ready, j)
+		}
+		if name := t.Name(); name != rawName {
+			phase.indices[name] = append(phase.indices[name], j)
+		}
+	}
+	return nil
+}
+
+// start runs the task if the task is ready.
+// If the task is finished without running, the task is completed immediately.
+func (sched *scheduler) start(ctx context.Context, idx int) {
+	task := sched.phase.Tasks.Get(idx)
+	if task.lazy && task.Result.Status == constant.Queue {
+		if err := sched.expand(ctx, idx); err != nil {
+			logrus.WithFields(logrus.Fields{
+				"task_name":  task.Name(),
+				"phase_name": sched.phase.Config.Name,
+			}).WithError(err).Error("failed to expand a task")
+		}
+		task = sched.phase.Tasks.Get(idx)
+	}
+	if sched.waitsExpansion(task) {
+		return
+	}
+	started, err := sched.phase.RunTask(ctx, idx, task, sched.params, sched.wd)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"task_name":  task.Name(),
+			"phase_name": sched.phase.Config.Name,
+		}).WithError(err).Error("failed to run a task")
+	}
+	if started {
+		sched.running++
+		return
+	}
+	if sched.phase.Tasks.Get(idx).Result.IsFinished() {
+		sched.complete(idx)
+	}
+}
+
+// complete updates the counters of dependents of the finished task.
+func (sched *scheduler) complete(idx int) {
+	sched.finished++
+	task := sched.phase.Tasks.Get(idx)
+	if task.lazy {
+		// the lazy task is finished without the expansion such as the failure of dependencies
+		sched.lazy--
+	}
+	sched.phase.failFast(task)
+	for _, dependent := range sched.dependents[idx] {
+		sched.pending[dependent]--
+		if sched.pending[dependent] == 0 {
+			sched.ready = append(sched.ready, dependent)
+		}
+	}
+}
+
+// dispatch runs ready tasks and tasks whose dependency is a tengo script
+// until no task becomes ready.
+func (sched *scheduler) dispatch(ctx context.Context) {
+	for {
+		for len(sched.ready) != 0 {
+			idx := sched.ready[0]
+			sched.ready = sched.ready[1:]
+			sched.start(ctx, idx)
+		}
+		finished := sched.finished
+		queued := sched.dynamic[:0]
+		for _, idx := range sched.dynamic {
+			sched.start(ctx, idx)
+			if sched.phase.Tasks.Get(idx).Result.Status == constant.Queue {
+				queued = append(queued, idx)
+			}
+		}
+		sched.dynamic = queued
+		// if a task is finished without running, other tasks may become ready
+		if len(sched.ready) == 0 && sched.finished == finished {
+			return
+		}
+	}
+}
+
+func (sched *scheduler) queuedTasks() []string {
+	names := []string{}
+	for _, task := range sched.phase.Tasks.GetAll() {
+		if task.Result.Status == constant.Queue {
+			names = append(names, task.Name())
+		}
+	}
+	return names
+}
+
+// Run runs tasks of the phase until all tasks are finished.
+// If ctx is cancelled or the timeout of the phase expires, queued tasks aren't started
+// and Run waits for running tasks which are cancelled.
+func (phase *Phase) Run(ctx context.Context, params Params, wd string) error {
+	// the context of tasks is cancelled by fail_fast or the timeout of the phase
+	taskCtx, cancel := context.WithCancel(ctx)
+	if phase.Config.Timeout > 0 {
+		taskCtx, cancel = context.WithTimeout(ctx, phase.Config.Timeout)
+	}
+	defer cancel()
+	phase.cancel = cancel
+	defer phase.checkTimeout(ctx, taskCtx)
+
+	sched := newScheduler(phase, params, wd)
+	sched.dispatch(taskCtx)
+	// the number of tasks is increased by the lazy expansion
+	for sched.finished < phase.Tasks.Size() {
+		if sched.running == 0 {
+			return errors.New("the phase isn't finished but no task running. Plase check the task dependency is wrong. queued tasks: " + strings.Join(sched.queuedTasks(), ", "))
+		}
+		idx := <-phase.events
+		sched.running--
+		sched.complete(idx)
+		sched.dispatch(taskCtx)
+	}
+	return nil
+}
+
+// checkTimeout sets the error of the phase if tasks timed out.
+func (phase *Phase) checkTimeout(ctx, taskCtx context.Context) {
+	if !errors.Is(taskCtx.Err(), context.DeadlineExceeded) {
+		return
+	}
+	timedOut := false
+	for _, task := range phase.Tasks.GetAll() {
+		if task.Result.Status == constant.TimedOut {
+			timedOut = true
+			break
+		}
+	}
+	if !timedOut {
+		return
+	}
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		phase.Error = errors.New("the build timed out")
+		return
+	}
+	phase.Error = fmt.Errorf("the phase timed out (timeout: %s)", phase.Config.Timeout)
+}
@@ -0,0 +1,332 @@
+package controller_test
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/suzuki-shunsuke/buildflow/pkg/config"
+	"github.com/suzuki-shunsuke/buildflow/pkg/controller"
+	"github.com/suzuki-shunsuke/buildflow/pkg/domain"
+	"github.com/suzuki-shunsuke/buildflow/pkg/execute"
+	"gopkg.in/yaml.v2"
+)
+
+type executor struct {
+	count *int64
+}
+
+func (exc executor) Run(ctx context.Context, params execute.Params) (domain.CommandResult, error) {
+	if exc.count != nil {
+		atomic.AddInt64(exc.count, 1)
+	}
+	return domain.CommandResult{}, nil
+}
+
+type timer struct{}
+
+func (t timer) Now() time.Time {
+	return time.Time{}
+}
+
+func newController(b *testing.B, cfgText string) controller.Controller {
+	cfg := config.Config{}
+	if err := yaml.UnmarshalStrict([]byte(cfgText), &cfg); err != nil {
+		b.Fatal(err)
+	}
+	cfg, err := config.Set(cfg)
+	if err != nil {
+		b.Fatal(err)
+	}
+	return controller.Controller{
+		Config:   cfg,
+		Executor: executor{},
+		Timer:    timer{},
+		Stdout:   ioutil.Discard,
+		Stderr:   ioutil.Discard,
+	}
+}
+
+func runBenchmark(b *testing.B, cfgText string) {
+	ctx := context.Background()
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		// the configuration is created every time because Controller.Run updates it
+		ctrl := newController(b, cfgText)
+		b.StartTimer()
+		if err := ctrl.Run(ctx, ""); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// independentTasks returns the configuration whose phase has n tasks expanded by items.
+func independentTasks(n int) string {
+	buf := &strings.Builder{}
+	buf.WriteString(`
+phases:
+- name: main
+  tasks:
+  - name: "task {{.Item.Value}}"
+    command:
+      command: "echo {{.Item.Value}}"
+    items:
+`)
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(buf, "    - %d\n", i)
+	}
+	return buf.String()
+}
+
+// chainedTasks returns the configuration whose phase has n tasks, each of which depends on the previous task.
+func chainedTasks(n int) string {
+	buf := &strings.Builder{}
+	buf.WriteString(`
+phases:
+- name: main
+  tasks:
+`)
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(buf, "  - name: task%d\n    command:\n      command: \"echo %d\"\n", i, i)
+		if i > 0 {
+			fmt.Fprintf(buf, "    dependency:\n    - task%d\n", i-1)
+		}
+	}
+	return buf.String()
+}
+
+// fanInTasks returns the configuration whose phase has n tasks and a task which depends on all of them.
+func fanInTasks(n int) string {
+	buf := &strings.Builder{}
+	buf.WriteString(`
+phases:
+- name: main
+  tasks:
+`)
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(buf, "  - name: task%d\n    command:\n      command: \"echo %d\"\n", i, i)
+	}
+	buf.WriteString("  - name: last\n    command:\n      command: echo last\n    dependency:\n")
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(buf, "    - task%d\n", i)
+	}
+	return buf.String()
+}
+
+func BenchmarkController_Run_independent1000(b *testing.B) {
+	runBenchmark(b, independentTasks(1000))
+}
+
+func BenchmarkController_Run_chain1000(b *testing.B) {
+	runBenchmark(b, chainedTasks(1000))
+}
+
+func BenchmarkController_Run_fanIn1000(b *testing.B) {
+	runBenchmark(b, fanInTasks(1000))
+}
+
+func TestController_Run_dependency(t *testing.T) {
+	data := []struct {
+		title string
+		cfg   string
+		exp   int64
+	}{
+		{
+			title: "independent tasks",
+			cfg:   independentTasks(100),
+			exp:   100,
+		},
+		{
+			title: "chained tasks",
+			cfg:   chainedTasks(100),
+			exp:   100,
+		},
+		{
+			title: "fan-in",
+			cfg:   fanInTasks(100),
+			exp:   101,
+		},
+		{
+			title: "the dependency is a tengo script",
+			cfg: `
+phases:
+- name: main
+  tasks:
+  - name: bar
+    command:
+      command: echo bar
+    dependency: |
+      result := Tasks[1].Status == "succeeded"
+  - name: foo
+    command:
+      command: echo foo
+  - name: zoo
+    command:
+      command: echo zoo
+    dependency:
+    - bar
+`,
+			exp: 3,
+		},
+		{
+			title: "the skipped task wakes the dependent",
+			cfg: `
+phases:
+- name: main
+  tasks:
+  - name: foo
+    command:
+      command: echo foo
+    when: false
+  - name: bar
+    command:
+      command: echo bar
+    dependency:
+    - foo
+`,
+			exp: 1,
+		},
+		{
+			title: "the task is expanded after its dependency is finished",
+			cfg: `
+phases:
+- name: main
+  tasks:
+  - name: discover
+    command:
+      command: echo discover
+  - name: "test {{.Item.Value}}"
+    command:
+      command: echo test
+    dependency:
+    - discover
+    items: |
+      result := Tasks[0].Status == "succeeded" ? [1, 2, 3] : []
+  - name: report
+    command:
+      command: echo report
+    # the task waits for all expanded tasks
+    dependency:
+    - "test {{.Item.Value}}"
+  - name: test 3 report
+    command:
+      command: echo report
+    dependency:
+    - test 3
+`,
+			exp: 6,
+		},
+		{
+			title: "finally phases and tasks",
+			cfg: `
+phases:
+- name: main
+  tasks:
+  - name: foo
+    command:
+      command: echo foo
+  finally:
+  - name: cleanup
+    command:
+      command: echo cleanup
+    dependency:
+    - foo
+  condition:
+    exit: true
+- name: not run
+  tasks:
+  - name: bar
+    command:
+      command: echo bar
+finally:
+- name: teardown
+  tasks:
+  - name: teardown
+    command:
+      command: echo teardown
+`,
+			exp: 3,
+		},
+	}
+	ctx := context.Background()
+	for _, d := range data {
+		d := d
+		t.Run(d.title, func(t *testing.T) {
+			cfg := config.Config{}
+			if err := yaml.UnmarshalStrict([]byte(d.cfg), &cfg); err != nil {
+				t.Fatal(err)
+			}
+			cfg, err := config.Set(cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var count int64
+			ctrl := controller.Controller{
+				Config:   cfg,
+				Executor: executor{count: &count},
+				Timer:    timer{},
+				Stdout:   ioutil.Discard,
+				Stderr:   ioutil.Discard,
+			}
+			if err := ctrl.Run(ctx, ""); err != nil {
+				t.Fatal(err)
+			}
+			// all tasks are run
+			assert.Equal(t, d.exp, count)
+		})
+	}
+}
+
+func TestController_Run_finallyAfterExpansionError(t *testing.T) {
+	cfg := config.Config{}
+	if err := yaml.UnmarshalStrict([]byte(`
+phases:
+- name: main
+  tasks:
+  - name: "foo {{.Item.Value}}"
+    command:
+      command: echo foo
+    items: |
+      result := 1
+  finally:
+  - name: cleanup
+    command:
+      command: echo cleanup
+`), &cfg); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := config.Set(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var count int64
+	ctrl := controller.Controller{
+		Config:   cfg,
+		Executor: executor{count: &count},
+		Timer:    timer{},
+		Stdout:   ioutil.Discard,
+		Stderr:   ioutil.Discard,
+	}
+	// the build fails because items is invalid
+	assert.NotNil(t, ctrl.Run(context.Background(), ""))
+	// the finally task is run
+	assert.Equal(t, int64(1), count)
+}
+
+// The benchmarks of 4000 tasks are compared with ones of 1000 tasks to check the scalability of the scheduler.
+func BenchmarkController_Run_independent4000(b *testing.B) {
+	runBenchmark(b, independentTasks(4000))
+}
+
+func BenchmarkController_Run_chain4000(b *testing.B) {
+	runBenchmark(b, chainedTasks(4000))
+}
+
+func BenchmarkController_Run_fanIn4000(b *testing.B) {
+	runBenchmark(b, fanInTasks(4000))
+}
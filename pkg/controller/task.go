@@ -2,17 +2,17 @@ package controller
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
-	"time"
+	"strconv"
 
 	"github.com/suzuki-shunsuke/buildflow/pkg/config"
 	"github.com/suzuki-shunsuke/buildflow/pkg/constant"
 	"github.com/suzuki-shunsuke/buildflow/pkg/domain"
 	"github.com/suzuki-shunsuke/buildflow/pkg/execute"
-	"github.com/suzuki-shunsuke/go-convmap/convmap"
-	"gopkg.in/yaml.v2"
+	"github.com/suzuki-shunsuke/buildflow/pkg/file"
+	"github.com/suzuki-shunsuke/buildflow/pkg/httpclient"
 )
 
 type Task struct {
@@ -21,15 +21,19 @@ type Task struct {
 	Executor   Executor
 	FileReader FileReader
 	FileWriter FileWriter
+	HTTPClient HTTPClient
+	Builder    Builder
+	Cache      Cache
 	Timer      Timer
 	Stdout     io.Writer
 	Stderr     io.Writer
+	// configPaths is the absolute paths of the configuration files of the build and its parent builds
+	configPaths []string
+	// lazy is true if the task is expanded after its dependencies are finished
+	lazy bool
 }
 
-func (task Task) runCommand(ctx context.Context, wd string) (domain.CommandResult, error) {
-	if task.Config.Timeout.Duration == 0 {
-		task.Config.Timeout.Duration = 1 * time.Hour
-	}
+func (task Task) runCommand(ctx context.Context) (domain.CommandResult, error) {
 	result, err := task.Executor.Run(ctx, execute.Params{
 		Cmd:        task.Config.Command.Shell,
 		Args:       append(task.Config.Command.ShellOpts, task.Config.Command.Command.Text),
@@ -38,16 +42,81 @@ func (task Task) runCommand(ctx context.Context, wd string) (domain.CommandResul
 		Stdout:     task.Stdout,
 		Stderr:     task.Stderr,
 		Stdin:      task.Config.Command.Stdin.Text,
-		WorkingDir: wd,
+		WorkingDir: task.Config.Dir.Text,
 		Envs:       task.Config.Command.Env.Compiled,
 	})
 	return result, err
 }
 
-func (task Task) run(ctx context.Context, wd string) (domain.Result, error) {
+func renderedTexts(tpls []config.Template) []string {
+	arr := make([]string, len(tpls))
+	for i, tpl := range tpls {
+		arr[i] = tpl.Text
+	}
+	return arr
+}
+
+func (task Task) listDir() ([]interface{}, error) {
+	files, err := task.FileReader.List(file.ParamsList{
+		Path:      task.Config.ReadFile.Path.Text,
+		Recursive: task.Config.ReadFile.Recursive,
+		Pattern:   task.Config.ReadFile.Pattern,
+	})
+	if err != nil {
+		return nil, err
+	}
+	arr := make([]interface{}, len(files))
+	for i, f := range files {
+		arr[i] = f.ToTemplate()
+	}
+	return arr, nil
+}
+
+func (task Task) runScript(ctx context.Context, params Params) (interface{}, error) {
+	c, cancel := context.WithTimeout(ctx, task.Config.Timeout.Duration)
+	defer cancel()
+	return task.Config.Script.RunContext(c, params.ToExpr())
+}
+
+func (task Task) runHTTP(ctx context.Context) (domain.HTTPResult, error) {
+	result, err := task.HTTPClient.Send(ctx, httpclient.ParamsSend{
+		Method:  task.Config.HTTP.Method.Text,
+		URL:     task.Config.HTTP.URL.Text,
+		Header:  task.Config.HTTP.CompiledHeader,
+		Query:   task.Config.HTTP.CompiledQuery,
+		Body:    task.Config.HTTP.Body.Text,
+		Timeout: task.Config.Timeout.Duration,
+	})
+	if err != nil {
+		return result, err
+	}
+	if !isExpectedStatusCode(result.StatusCode, task.Config.HTTP.StatusCodes) {
+		return result, errors.New("unexpected http status code: " + strconv.Itoa(result.StatusCode))
+	}
+	d, err := decodeData(task.Config.HTTP.Format, result.Text, false)
+	if err != nil {
+		return result, fmt.Errorf("failed to parse the response body: %w", err)
+	}
+	result.Data = d
+	return result, nil
+}
+
+func isExpectedStatusCode(code int, codes []int) bool {
+	if len(codes) == 0 {
+		return code >= 200 && code < 300 //nolint:gomnd
+	}
+	for _, c := range codes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+func (task Task) run(ctx context.Context, params Params) (domain.Result, error) {
 	switch task.Config.Type {
 	case constant.Command:
-		cmdResult, err := task.runCommand(ctx, wd)
+		cmdResult, err := task.runCommand(ctx)
 		return domain.Result{
 			Command: cmdResult,
 		}, err
@@ -59,35 +128,56 @@ func (task Task) run(ctx context.Context, wd string) (domain.Result, error) {
 		if err != nil {
 			return result, err
 		}
-		switch task.Config.ReadFile.Format {
-		case "":
-			return result, err
-		case "json":
-			var d interface{}
-			if err := json.Unmarshal([]byte(fileResult.Text), &d); err != nil {
-				return result, err
-			}
-			result.File.Data = d
-			return result, nil
-		case "yaml":
-			var d interface{}
-			if err := yaml.Unmarshal([]byte(fileResult.Text), &d); err != nil {
-				return result, err
-			}
-			a, err := convmap.Convert(d)
+		if fileResult.IsDir {
+			d, err := task.listDir()
 			if err != nil {
-				return result, err
+				return result, fmt.Errorf("failed to list files in the directory %s: %w", fileResult.Path, err)
 			}
-			result.File.Data = a
+			result.File.Data = d
 			return result, nil
-		// case "toml":
-		default:
-			return result, errors.New("invalid file.format: " + task.Config.ReadFile.Format)
 		}
+		d, err := decodeData(task.Config.ReadFile.Format, fileResult.Text, task.Config.ReadFile.CSVHeader)
+		if err != nil {
+			return result, fmt.Errorf("failed to parse the file as %s: %s: %w", task.Config.ReadFile.Format, task.Config.ReadFile.Path.Text, err)
+		}
+		result.File.Data = d
+		return result, nil
+	case constant.Glob:
+		files, err := task.FileReader.Glob(file.ParamsGlob{
+			Root:           task.Config.Glob.Root.Text,
+			Include:        renderedTexts(task.Config.Glob.Include),
+			Exclude:        renderedTexts(task.Config.Glob.Exclude),
+			FollowSymlinks: task.Config.Glob.FollowSymlinks,
+		})
+		return domain.Result{
+			Glob: files,
+		}, err
+	case constant.Script:
+		output, err := task.runScript(ctx, params)
+		return domain.Result{
+			Output: output,
+		}, err
+	case constant.Buildflow:
+		buildResult, err := task.runBuildflow(ctx, params)
+		return domain.Result{
+			Build: buildResult,
+			// the child build result is the default output
+			Output: buildResult.ToTemplate(),
+		}, err
+	case constant.HTTP:
+		httpResult, err := task.runHTTP(ctx)
+		return domain.Result{
+			HTTP: httpResult,
+		}, err
 	case constant.WriteFile:
-		// TODO append a new line
-		fileResult, err := task.FileWriter.Write(
-			task.Config.WriteFile.Path.Text, task.Config.WriteFile.Template.Text+"\n")
+		fileResult, err := task.FileWriter.Write(file.ParamsWrite{
+			Path:   task.Config.WriteFile.Path.Text,
+			Text:   task.Config.WriteFile.Content,
+			Mode:   task.Config.WriteFile.FileMode,
+			Append: task.Config.WriteFile.Append,
+			Mkdir:  task.Config.WriteFile.Mkdir,
+			Atomic: task.Config.WriteFile.Atomic,
+		})
 		return domain.Result{
 			File: fileResult,
 		}, err
@@ -95,9 +185,9 @@ func (task Task) run(ctx context.Context, wd string) (domain.Result, error) {
 	return domain.Result{}, errors.New("invalid task type: " + task.Config.Type + ", task name: " + task.Name())
 }
 
-func (task Task) Run(ctx context.Context, wd string) (domain.Result, error) {
+func (task Task) Run(ctx context.Context, params Params) (domain.Result, error) {
 	startTime := task.Timer.Now()
-	result, err := task.run(ctx, wd)
+	result, err := task.run(ctx, params)
 	result.Time.Start = startTime
 	result.Time.End = task.Timer.Now()
 	return result, err
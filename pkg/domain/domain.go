@@ -15,6 +15,10 @@ type Result struct {
 	// running
 	// skipped
 	// cancelled
+	// warning
+	// upstream_failed
+	// timed_out
+	// cached
 	Status  string
 	Input   interface{}
 	Output  interface{}
@@ -22,8 +26,12 @@ type Result struct {
 	Type    string
 	Command CommandResult
 	File    FileResult
+	Glob    []FileResult
 	HTTP    HTTPResult
-	Error   error
+	Build   BuildResult
+	// the results of the previous attempts
+	Attempts []Result
+	Error    error
 }
 
 type PhaseResult struct {
@@ -41,6 +49,16 @@ func (result Result) IsFinished() bool {
 	return !(result.Status == constant.Running || result.Status == constant.Queue)
 }
 
+// IsFailed returns true if the task failed or the task wasn't run because the dependency failed or the task was cancelled.
+func (result Result) IsFailed() bool {
+	switch result.Status {
+	case constant.Failed, constant.UpstreamFailed, constant.Cancelled, constant.TimedOut:
+		return true
+	default:
+		return false
+	}
+}
+
 type Time struct {
 	Start time.Time
 	End   time.Time
@@ -76,7 +94,40 @@ func (fileResult FileResult) ToTemplate() map[string]interface{} {
 	}
 }
 
+type BuildResult struct {
+	Status string
+	Phases map[string]interface{}
+}
+
+func (buildResult BuildResult) ToTemplate() map[string]interface{} {
+	return map[string]interface{}{
+		"Status": buildResult.Status,
+		"Phases": buildResult.Phases,
+	}
+}
+
 type HTTPResult struct {
-	Text     string
-	Response *http.Response
+	StatusCode int
+	Status     string
+	Header     http.Header
+	Text       string
+	Data       interface{}
+}
+
+func (httpResult HTTPResult) ToTemplate() map[string]interface{} {
+	header := make(map[string]interface{}, len(httpResult.Header))
+	for k, vals := range httpResult.Header {
+		arr := make([]interface{}, len(vals))
+		for i, v := range vals {
+			arr[i] = v
+		}
+		header[k] = arr
+	}
+	return map[string]interface{}{
+		"StatusCode": httpResult.StatusCode,
+		"Status":     httpResult.Status,
+		"Header":     header,
+		"Text":       httpResult.Text,
+		"Data":       httpResult.Data,
+	}
 }
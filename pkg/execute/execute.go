@@ -12,6 +12,7 @@ import (
 
 	"github.com/Songmu/timeout"
 	"github.com/suzuki-shunsuke/buildflow/pkg/domain"
+	"github.com/suzuki-shunsuke/buildflow/pkg/signal"
 	"github.com/suzuki-shunsuke/go-error-with-exit-code/ecerror"
 )
 
@@ -78,7 +79,7 @@ func (exc Executor) Run(ctx context.Context, params Params) (domain.CommandResul
 		Duration:  params.Timeout.Duration,
 		KillAfter: params.Timeout.KillAfter,
 	}
-	exitStatus, err := tio.RunContext(ctx)
+	exitStatus, err := run(ctx, &tio, params.Timeout.KillAfter)
 	result := domain.CommandResult{
 		Cmd:            cmd.String(),
 		Stdout:         bufStdout.String(),
@@ -95,3 +96,39 @@ func (exc Executor) Run(ctx context.Context, params Params) (domain.CommandResul
 	}
 	return result, nil
 }
+
+// DefaultGracePeriod is the period to wait for the cancelled command to exit if kill_after isn't set.
+const DefaultGracePeriod = 10 * time.Second
+
+// run runs the command and waits for the command to exit.
+// When the context is cancelled, the signal which cancelled the build (by default SIGTERM) is forwarded to the command,
+// and the command is killed if it doesn't exit in the grace period.
+func run(ctx context.Context, tio *timeout.Timeout, gracePeriod time.Duration) (*timeout.ExitStatus, error) {
+	ch, err := tio.RunCommand()
+	if err != nil {
+		return nil, err
+	}
+	select {
+	case exitStatus := <-ch:
+		return exitStatus, nil
+	case <-ctx.Done():
+	}
+	sig := signal.Received(ctx)
+	if sig == nil {
+		sig = defaultSignal
+	}
+	// the error is ignored because the command may have already exited
+	sendSignal(tio.Cmd.Process, sig) //nolint:errcheck
+	if gracePeriod <= 0 {
+		gracePeriod = DefaultGracePeriod
+	}
+	timer := time.NewTimer(gracePeriod)
+	defer timer.Stop()
+	select {
+	case exitStatus := <-ch:
+		return exitStatus, nil
+	case <-timer.C:
+	}
+	killProcess(tio.Cmd.Process) //nolint:errcheck
+	return <-ch, nil
+}
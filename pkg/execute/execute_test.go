@@ -3,6 +3,7 @@ package execute_test
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/suzuki-shunsuke/buildflow/pkg/execute"
@@ -55,3 +56,24 @@ func TestExecutor_Run(t *testing.T) {
 		})
 	}
 }
+
+func TestExecutor_Run_cancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	exc := execute.New()
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		cancel()
+	}()
+	start := time.Now()
+	// the signal is forwarded to the command when the context is cancelled
+	_, err := exc.Run(ctx, execute.Params{
+		Cmd:  "sleep",
+		Args: []string{"60"},
+		Timeout: execute.Timeout{
+			Duration:  time.Minute,
+			KillAfter: time.Second,
+		},
+	})
+	assert.NotNil(t, err)
+	assert.Less(t, int64(time.Since(start)), int64(10*time.Second))
+}
@@ -0,0 +1,29 @@
+//go:build !windows
+// +build !windows
+
+package execute
+
+import (
+	"os"
+	"syscall"
+)
+
+var defaultSignal os.Signal = syscall.SIGTERM //nolint:gochecknoglobals
+
+// sendSignal sends the signal to the process group of the command,
+// because the command is run in the new process group.
+func sendSignal(proc *os.Process, sig os.Signal) error {
+	s, ok := sig.(syscall.Signal)
+	if !ok {
+		return proc.Signal(sig)
+	}
+	if err := syscall.Kill(-proc.Pid, s); err != nil {
+		return err
+	}
+	// resume the stopped process so that the process can handle the signal
+	return syscall.Kill(-proc.Pid, syscall.SIGCONT)
+}
+
+func killProcess(proc *os.Process) error {
+	return syscall.Kill(-proc.Pid, syscall.SIGKILL)
+}
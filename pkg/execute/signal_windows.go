@@ -0,0 +1,16 @@
+package execute
+
+import (
+	"os"
+)
+
+var defaultSignal = os.Kill //nolint:gochecknoglobals
+
+// sendSignal kills the process, because sending other signals isn't supported on Windows.
+func sendSignal(proc *os.Process, sig os.Signal) error {
+	return proc.Kill()
+}
+
+func killProcess(proc *os.Process) error {
+	return proc.Kill()
+}
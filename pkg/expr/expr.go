@@ -9,28 +9,86 @@ import (
 	"github.com/suzuki-shunsuke/buildflow/pkg/constant"
 )
 
+var modules = stdlib.GetModuleMap(stdlib.AllModuleNames()...) //nolint:gochecknoglobals
+
+// variables is the names of the variables which are passed to scripts by the controller (see controller.Params.ToExpr).
+var variables = []string{"Build", "Files", "Input", "Item", "Matrix", "Meta", "PR", "Phase", "Phases", "Task", "Tasks"} //nolint:gochecknoglobals
+
+// compiler compiles the script once and reuses the compiled script.
+// The variables of the tengo script must be defined at the compile time,
+// so the script is compiled with all variables which can be passed to the script.
+type compiler struct {
+	compiled *tengo.Compiled
+}
+
+// newCompiler compiles the script so that errors such as the syntax error and the undefined variable are found before the script is run.
+func newCompiler(source string) (*compiler, error) {
+	if source == "" {
+		return nil, nil
+	}
+	script := tengo.NewScript([]byte(source))
+	script.SetImports(modules)
+	for _, name := range variables {
+		if err := script.Add(name, nil); err != nil {
+			return nil, err
+		}
+	}
+	compiled, err := script.Compile()
+	if err != nil {
+		return nil, err
+	}
+	return &compiler{
+		compiled: compiled,
+	}, nil
+}
+
+// run runs the clone of the compiled script with the variables.
+// The variables which aren't passed are undefined.
+func (c *compiler) run(ctx context.Context, params map[string]interface{}) (*tengo.Compiled, error) {
+	clone := c.compiled.Clone()
+	for k, v := range params {
+		if err := clone.Set(k, v); err != nil {
+			return nil, err
+		}
+	}
+	if err := clone.RunContext(ctx); err != nil {
+		return nil, err
+	}
+	return clone, nil
+}
+
 type Program struct {
-	source string
+	source   string
+	compiler *compiler
 }
 
 func New(expression string) (Program, error) {
+	c, err := newCompiler(expression)
+	if err != nil {
+		return Program{}, err
+	}
 	return Program{
-		source: expression,
+		source:   expression,
+		compiler: c,
 	}, nil
 }
 
+// Empty returns true if the script isn't set.
+func (prog Program) Empty() bool {
+	return prog.source == ""
+}
+
 func (prog Program) Run(params map[string]interface{}) (interface{}, error) {
+	return prog.RunContext(context.Background(), params)
+}
+
+// RunContext runs the program.
+// If the context is cancelled, the program is aborted.
+func (prog Program) RunContext(ctx context.Context, params map[string]interface{}) (interface{}, error) {
 	if prog.source == "" {
 		return nil, nil
 	}
-	script := tengo.NewScript([]byte(prog.source))
-	script.SetImports(stdlib.GetModuleMap(stdlib.AllModuleNames()...))
-	for k, v := range params {
-		if err := script.Add(k, v); err != nil {
-			return nil, err
-		}
-	}
-	compiled, err := script.RunContext(context.Background())
+	compiled, err := prog.compiler.run(ctx, params)
 	if err != nil {
 		return nil, err
 	}
@@ -43,28 +101,31 @@ func (prog Program) Run(params map[string]interface{}) (interface{}, error) {
 }
 
 type BoolProgram struct {
-	source string
+	source   string
+	compiler *compiler
 }
 
 func NewBool(expression string) (BoolProgram, error) {
+	c, err := newCompiler(expression)
+	if err != nil {
+		return BoolProgram{}, err
+	}
 	return BoolProgram{
-		source: expression,
+		source:   expression,
+		compiler: c,
 	}, nil
 }
 
+// Empty returns true if the script isn't set.
+func (prog BoolProgram) Empty() bool {
+	return prog.source == ""
+}
+
 func (prog BoolProgram) Match(params map[string]interface{}) (bool, error) {
 	if prog.source == "" {
 		return true, nil
 	}
-	script := tengo.NewScript([]byte(prog.source))
-	script.SetImports(stdlib.GetModuleMap(stdlib.AllModuleNames()...))
-
-	for k, v := range params {
-		if err := script.Add(k, v); err != nil {
-			return false, err
-		}
-	}
-	compiled, err := script.RunContext(context.Background())
+	compiled, err := prog.compiler.run(context.Background(), params)
 	if err != nil {
 		return false, err
 	}
@@ -0,0 +1,146 @@
+package expr_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/suzuki-shunsuke/buildflow/pkg/expr"
+)
+
+func TestNew(t *testing.T) {
+	data := []struct {
+		title string
+		src   string
+		isErr bool
+	}{
+		{
+			title: "empty",
+		},
+		{
+			title: "valid",
+			src:   "result := Meta.foo + 1",
+		},
+		{
+			title: "syntax error is found before the script is run",
+			src:   "result := (",
+			isErr: true,
+		},
+		{
+			title: "undefined variable is found before the script is run",
+			src:   `result := Taks.Status == "x"`,
+			isErr: true,
+		},
+	}
+	for _, d := range data {
+		d := d
+		t.Run(d.title, func(t *testing.T) {
+			_, err := expr.New(d.src)
+			if d.isErr {
+				assert.NotNil(t, err)
+			} else {
+				assert.Nil(t, err)
+			}
+			_, err = expr.NewBool(d.src)
+			if d.isErr {
+				assert.NotNil(t, err)
+			} else {
+				assert.Nil(t, err)
+			}
+		})
+	}
+}
+
+func TestProgram_Run(t *testing.T) {
+	prog, err := expr.New(`
+text := import("text")
+result := text.repeat(Meta, 2)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the compiled script is reused with fresh variables
+	for _, s := range []string{"a", "b"} {
+		a, err := prog.Run(map[string]interface{}{
+			"Meta": s,
+		})
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Equal(t, s+s, a)
+	}
+	// the set of variables is changed
+	a, err := prog.Run(map[string]interface{}{
+		"Meta":  "c",
+		"Input": "d",
+	})
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, "cc", a)
+	// the unknown variable can't be passed
+	_, err = prog.Run(map[string]interface{}{
+		"Meta": "e",
+		"foo":  "f",
+	})
+	assert.NotNil(t, err)
+}
+
+func TestBoolProgram_Match(t *testing.T) {
+	data := []struct {
+		title  string
+		src    string
+		params map[string]interface{}
+		exp    bool
+		isErr  bool
+	}{
+		{
+			title: "empty",
+			exp:   true,
+		},
+		{
+			title: "true",
+			src:   "result := Meta == 1",
+			params: map[string]interface{}{
+				"Meta": 1,
+			},
+			exp: true,
+		},
+		{
+			title: "false",
+			src:   "result := Meta == 1",
+			params: map[string]interface{}{
+				"Meta": 2,
+			},
+		},
+		{
+			title: "result isn't boolean",
+			src:   "result := Meta",
+			params: map[string]interface{}{
+				"Meta": 2,
+			},
+			isErr: true,
+		},
+		{
+			title: "the variable isn't passed",
+			src:   "result := Meta",
+			isErr: true,
+		},
+	}
+	for _, d := range data {
+		d := d
+		t.Run(d.title, func(t *testing.T) {
+			prog, err := expr.NewBool(d.src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			f, err := prog.Match(d.params)
+			if d.isErr {
+				assert.NotNil(t, err)
+				return
+			}
+			if !assert.Nil(t, err) {
+				return
+			}
+			assert.Equal(t, d.exp, f)
+		})
+	}
+}
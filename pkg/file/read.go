@@ -1,9 +1,13 @@
 package file
 
 import (
+	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"sort"
 
+	"github.com/bmatcuk/doublestar"
 	"github.com/suzuki-shunsuke/buildflow/pkg/domain"
 )
 
@@ -12,46 +16,293 @@ type (
 	Writer struct{}
 )
 
+func newResult(path, text string, stat os.FileInfo) domain.FileResult {
+	return domain.FileResult{
+		Text:    text,
+		Path:    path,
+		ModTime: stat.ModTime(),
+		Size:    stat.Size(),
+		Mode:    stat.Mode(),
+		IsDir:   stat.IsDir(),
+	}
+}
+
+// Read reads a file and returns the content and the file information.
+// If the path is a directory, the content isn't read.
 func (reader Reader) Read(path string) (domain.FileResult, error) {
+	stat, err := os.Stat(path)
+	if err != nil {
+		return domain.FileResult{}, err
+	}
+	if stat.IsDir() {
+		return newResult(path, "", stat), nil
+	}
 	b, err := ioutil.ReadFile(path)
 	if err != nil {
 		return domain.FileResult{}, err
 	}
-	return domain.FileResult{
-		Text: string(b),
-	}, nil
+	return newResult(path, string(b), stat), nil
+}
+
+type ParamsList struct {
+	Path      string
+	Recursive bool
+	Pattern   string
 }
 
-func (writer Writer) openWriteFile(path string) (*os.File, error) {
-	if path == "" {
+func matchPattern(pattern, path string) (bool, error) {
+	if pattern == "" {
+		return true, nil
+	}
+	return filepath.Match(pattern, filepath.Base(path))
+}
+
+// List returns the list of files in the directory.
+// The pattern is matched with the file name.
+func (reader Reader) List(params ParamsList) ([]domain.FileResult, error) {
+	if !params.Recursive {
+		infos, err := ioutil.ReadDir(params.Path)
+		if err != nil {
+			return nil, err
+		}
+		results := make([]domain.FileResult, 0, len(infos))
+		for _, info := range infos {
+			p := filepath.Join(params.Path, info.Name())
+			if f, err := matchPattern(params.Pattern, p); err != nil {
+				return nil, err
+			} else if !f {
+				continue
+			}
+			results = append(results, newResult(p, "", info))
+		}
+		return results, nil
+	}
+	results := []domain.FileResult{}
+	err := filepath.Walk(params.Path, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if p == params.Path {
+			return nil
+		}
+		if f, err := matchPattern(params.Pattern, p); err != nil {
+			return err
+		} else if !f {
+			return nil
+		}
+		results = append(results, newResult(p, "", info))
+		return nil
+	})
+	return results, err
+}
+
+type ParamsWrite struct {
+	Path   string
+	Text   string
+	Mode   os.FileMode
+	Append bool
+	Mkdir  bool
+	Atomic bool
+}
+
+const defaultFileMode os.FileMode = 0o644
+
+func (writer Writer) openWriteFile(params ParamsWrite) (*os.File, error) {
+	if params.Path == "" {
 		return ioutil.TempFile("", "")
 	}
-	return os.Create(path)
+	if params.Atomic {
+		return ioutil.TempFile(filepath.Dir(params.Path), "."+filepath.Base(params.Path)+".*.tmp")
+	}
+	mode := params.Mode
+	if mode == 0 {
+		mode = 0o666 //nolint:gomnd
+	}
+	flag := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if params.Append {
+		flag = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	}
+	return os.OpenFile(params.Path, flag, mode)
 }
 
-func (writer Writer) Write(path, text string) (domain.FileResult, error) {
-	b := []byte(text)
-	f, err := writer.openWriteFile(path)
+// getAtomicFileMode returns the permission of the file which is renamed to path.
+// The permission of the existing file is kept by default.
+func (writer Writer) getAtomicFileMode(params ParamsWrite) os.FileMode {
+	if params.Mode != 0 {
+		return params.Mode
+	}
+	if stat, err := os.Stat(params.Path); err == nil {
+		return stat.Mode().Perm()
+	}
+	return defaultFileMode
+}
+
+func (writer Writer) Write(params ParamsWrite) (domain.FileResult, error) {
+	if params.Mkdir && params.Path != "" {
+		if err := os.MkdirAll(filepath.Dir(params.Path), 0o755); err != nil { //nolint:gomnd
+			return domain.FileResult{}, err
+		}
+	}
+	f, err := writer.openWriteFile(params)
 	if err != nil {
 		return domain.FileResult{}, err
 	}
 	defer f.Close()
 
-	if _, err := f.Write(b); err != nil {
+	if _, err := f.Write([]byte(params.Text)); err != nil {
 		return domain.FileResult{}, err
 	}
 
+	if params.Atomic && params.Path != "" {
+		return writer.commitAtomic(f, params)
+	}
+
+	if params.Mode != 0 {
+		if err := f.Chmod(params.Mode); err != nil {
+			return domain.FileResult{}, err
+		}
+	}
+
 	stat, err := f.Stat()
 	if err != nil {
 		return domain.FileResult{}, err
 	}
 
-	return domain.FileResult{
-		Text:    text,
-		Path:    f.Name(),
-		ModTime: stat.ModTime(),
-		Size:    stat.Size(),
-		Mode:    stat.Mode(),
-		IsDir:   stat.IsDir(),
-	}, nil
+	return newResult(f.Name(), params.Text, stat), nil
+}
+
+// commitAtomic replaces the file with the temporal file f.
+// If it fails to write the file, the temporal file is removed and the original file isn't changed.
+func (writer Writer) commitAtomic(f *os.File, params ParamsWrite) (domain.FileResult, error) {
+	tmpPath := f.Name()
+	err := func() error {
+		if err := f.Chmod(writer.getAtomicFileMode(params)); err != nil {
+			return err
+		}
+		if err := f.Sync(); err != nil {
+			return err
+		}
+		if err := f.Close(); err != nil {
+			return err
+		}
+		return os.Rename(tmpPath, params.Path)
+	}()
+	if err != nil {
+		os.Remove(tmpPath)
+		return domain.FileResult{}, err
+	}
+	stat, err := os.Stat(params.Path)
+	if err != nil {
+		return domain.FileResult{}, err
+	}
+	return newResult(params.Path, params.Text, stat), nil
+}
+
+type ParamsGlob struct {
+	Root           string
+	Include        []string
+	Exclude        []string
+	FollowSymlinks bool
+}
+
+// Glob returns the list of files under the root directory which match with any include patterns and don't match with all exclude patterns.
+// Patterns are matched with the relative path from the root directory and `**` matches with any directories.
+// The list is sorted by the path.
+func (reader Reader) Glob(params ParamsGlob) ([]domain.FileResult, error) {
+	results := []domain.FileResult{}
+	if err := reader.walkGlob(params, params.Root, map[string]struct{}{}, &results); err != nil {
+		return nil, err
+	}
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Path < results[j].Path
+	})
+	return results, nil
+}
+
+func matchAny(patterns []string, p string) (bool, error) {
+	for _, pattern := range patterns {
+		f, err := doublestar.Match(pattern, p)
+		if err != nil {
+			return false, fmt.Errorf("invalid glob pattern %s: %w", pattern, err)
+		}
+		if f {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (reader Reader) matchGlob(params ParamsGlob, p string) (bool, error) {
+	rel, err := filepath.Rel(params.Root, p)
+	if err != nil {
+		return false, err
+	}
+	rel = filepath.ToSlash(rel)
+	if f, err := matchAny(params.Include, rel); err != nil || !f {
+		return false, err
+	}
+	f, err := matchAny(params.Exclude, rel)
+	return !f, err
+}
+
+// excludeDir returns true if all files under the directory are excluded,
+// so that the excluded directory such as node_modules isn't walked.
+func (reader Reader) excludeDir(params ParamsGlob, p string) (bool, error) {
+	rel, err := filepath.Rel(params.Root, p)
+	if err != nil {
+		return false, err
+	}
+	rel = filepath.ToSlash(rel)
+	if f, err := matchAny(params.Exclude, rel); err != nil || f {
+		return f, err
+	}
+	return matchAny(params.Exclude, rel+"/**")
+}
+
+func (reader Reader) walkGlob(params ParamsGlob, dir string, visited map[string]struct{}, results *[]domain.FileResult) error {
+	// avoid the infinite loop by symbolic links which refer to the ancestor directory
+	realPath, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return err
+	}
+	if _, ok := visited[realPath]; ok {
+		return nil
+	}
+	visited[realPath] = struct{}{}
+	defer delete(visited, realPath)
+
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, info := range infos {
+		p := filepath.Join(dir, info.Name())
+		if params.FollowSymlinks && info.Mode()&os.ModeSymlink != 0 {
+			// a broken symbolic link is treated as a file
+			if stat, err := os.Stat(p); err == nil {
+				info = stat
+			}
+		}
+		f, err := reader.matchGlob(params, p)
+		if err != nil {
+			return err
+		}
+		if f {
+			*results = append(*results, newResult(p, "", info))
+		}
+		if !info.IsDir() {
+			continue
+		}
+		excluded, err := reader.excludeDir(params, p)
+		if err != nil {
+			return err
+		}
+		if excluded {
+			continue
+		}
+		if err := reader.walkGlob(params, p, visited, results); err != nil {
+			return err
+		}
+	}
+	return nil
 }
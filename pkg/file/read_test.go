@@ -0,0 +1,250 @@
+package file_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/suzuki-shunsuke/buildflow/pkg/file"
+)
+
+func TestReader_Glob(t *testing.T) { //nolint:funlen
+	root, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	for _, p := range []string{"go.mod", "foo/go.mod", "foo/bar/go.mod", "foo/main.go", "vendor/zoo/go.mod"} {
+		p = filepath.Join(root, p)
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(p, nil, 0o644); err != nil { //nolint:gosec
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink(filepath.Join(root, "foo"), filepath.Join(root, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	data := []struct {
+		title  string
+		params file.ParamsGlob
+		exp    []string
+	}{
+		{
+			title: "**",
+			params: file.ParamsGlob{
+				Include: []string{"**/go.mod"},
+			},
+			exp: []string{"foo/bar/go.mod", "foo/go.mod", "go.mod", "vendor/zoo/go.mod"},
+		},
+		{
+			title: "exclude",
+			params: file.ParamsGlob{
+				Include: []string{"**/go.mod"},
+				Exclude: []string{"vendor/**"},
+			},
+			exp: []string{"foo/bar/go.mod", "foo/go.mod", "go.mod"},
+		},
+		{
+			title: "follow symlinks",
+			params: file.ParamsGlob{
+				Include:        []string{"link/*"},
+				FollowSymlinks: true,
+			},
+			exp: []string{"link/bar", "link/go.mod", "link/main.go"},
+		},
+		{
+			title: "don't follow symlinks",
+			params: file.ParamsGlob{
+				Include: []string{"link/*"},
+			},
+			exp: []string{},
+		},
+	}
+	reader := file.Reader{}
+	for _, d := range data {
+		d := d
+		t.Run(d.title, func(t *testing.T) {
+			d.params.Root = root
+			files, err := reader.Glob(d.params)
+			if !assert.Nil(t, err) {
+				return
+			}
+			paths := make([]string, len(files))
+			for i, f := range files {
+				rel, err := filepath.Rel(root, f.Path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				paths[i] = filepath.ToSlash(rel)
+			}
+			assert.Equal(t, d.exp, paths)
+		})
+	}
+}
+
+func TestReader_Glob_excludedDirectory(t *testing.T) {
+	root, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	if err := ioutil.WriteFile(filepath.Join(root, "go.mod"), nil, 0o644); err != nil { //nolint:gosec
+		t.Fatal(err)
+	}
+	// the excluded directory can't be read, so Glob fails if the directory is walked
+	if err := os.MkdirAll(filepath.Join(root, "foo", "node_modules"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(filepath.Join(root, "foo", "node_modules"), 0); err != nil {
+		t.Fatal(err)
+	}
+	reader := file.Reader{}
+	files, err := reader.Glob(file.ParamsGlob{
+		Root:    root,
+		Include: []string{"**/go.mod"},
+		Exclude: []string{"**/node_modules/**"},
+	})
+	if !assert.Nil(t, err) {
+		return
+	}
+	if assert.Len(t, files, 1) {
+		assert.Equal(t, filepath.Join(root, "go.mod"), files[0].Path)
+	}
+}
+
+func TestWriter_Write(t *testing.T) { //nolint:funlen
+	data := []struct {
+		title string
+		// the content and the permission of the existing file
+		// If the content is nil, the file doesn't exist.
+		content *string
+		perm    os.FileMode
+		params  file.ParamsWrite
+		isErr   bool
+		exp     string
+		expPerm os.FileMode
+	}{
+		{
+			title:  "new file",
+			params: file.ParamsWrite{Path: "foo.txt", Text: "foo\n"},
+			exp:    "foo\n",
+		},
+		{
+			title:   "overwrite",
+			content: strPtr("foo\n"),
+			perm:    0o644,
+			params:  file.ParamsWrite{Path: "foo.txt", Text: "bar\n"},
+			exp:     "bar\n",
+		},
+		{
+			title:   "append",
+			content: strPtr("foo\n"),
+			perm:    0o644,
+			params:  file.ParamsWrite{Path: "foo.txt", Text: "bar\n", Append: true},
+			exp:     "foo\nbar\n",
+		},
+		{
+			title:   "mode",
+			params:  file.ParamsWrite{Path: "foo.sh", Text: "#!/bin/sh\n", Mode: 0o755},
+			exp:     "#!/bin/sh\n",
+			expPerm: 0o755,
+		},
+		{
+			title:   "mode of the existing file",
+			content: strPtr("foo\n"),
+			perm:    0o644,
+			params:  file.ParamsWrite{Path: "foo.sh", Text: "#!/bin/sh\n", Mode: 0o755},
+			exp:     "#!/bin/sh\n",
+			expPerm: 0o755,
+		},
+		{
+			title:  "the parent directory doesn't exist",
+			params: file.ParamsWrite{Path: "foo/bar/foo.txt", Text: "foo\n"},
+			isErr:  true,
+		},
+		{
+			title:  "mkdir",
+			params: file.ParamsWrite{Path: "foo/bar/foo.txt", Text: "foo\n", Mkdir: true},
+			exp:    "foo\n",
+		},
+		{
+			title:   "atomic",
+			params:  file.ParamsWrite{Path: "foo.txt", Text: "foo\n", Atomic: true},
+			exp:     "foo\n",
+			expPerm: 0o644,
+		},
+		{
+			title:   "atomic keeps the permission of the existing file",
+			content: strPtr("foo\n"),
+			perm:    0o600,
+			params:  file.ParamsWrite{Path: "foo.txt", Text: "bar\n", Atomic: true},
+			exp:     "bar\n",
+			expPerm: 0o600,
+		},
+		{
+			title:   "atomic with mode",
+			content: strPtr("foo\n"),
+			perm:    0o644,
+			params:  file.ParamsWrite{Path: "foo.sh", Text: "#!/bin/sh\n", Atomic: true, Mode: 0o755},
+			exp:     "#!/bin/sh\n",
+			expPerm: 0o755,
+		},
+	}
+	writer := file.Writer{}
+	for _, d := range data {
+		d := d
+		t.Run(d.title, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+			p := filepath.Join(dir, d.params.Path)
+			if d.content != nil {
+				if err := ioutil.WriteFile(p, []byte(*d.content), d.perm); err != nil {
+					t.Fatal(err)
+				}
+			}
+			d.params.Path = p
+			result, err := writer.Write(d.params)
+			if d.isErr {
+				assert.NotNil(t, err)
+				return
+			}
+			if !assert.Nil(t, err) {
+				return
+			}
+			assert.Equal(t, p, result.Path)
+			b, err := ioutil.ReadFile(p)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, d.exp, string(b))
+			if d.expPerm != 0 {
+				stat, err := os.Stat(p)
+				if err != nil {
+					t.Fatal(err)
+				}
+				assert.Equal(t, d.expPerm, stat.Mode().Perm())
+			}
+			// the temporal file of atomic mode is removed
+			infos, err := ioutil.ReadDir(filepath.Dir(p))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, info := range infos {
+				assert.False(t, strings.HasSuffix(info.Name(), ".tmp"), info.Name())
+			}
+		})
+	}
+}
+
+func strPtr(s string) *string {
+	return &s
+}
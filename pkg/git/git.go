@@ -0,0 +1,148 @@
+package git
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+type Client struct{}
+
+type ParamsChangedFiles struct {
+	WorkingDir string
+	BaseBranch string
+	Staged     bool
+}
+
+// File is a changed file.
+// The attribute names are same as the response body of GitHub API "List pull requests files".
+type File struct {
+	Filename         string
+	Status           string
+	PreviousFilename string
+}
+
+func (file File) ToTemplate() map[string]interface{} {
+	m := map[string]interface{}{
+		"filename": file.Filename,
+		"status":   file.Status,
+	}
+	if file.PreviousFilename != "" {
+		m["previous_filename"] = file.PreviousFilename
+	}
+	return m
+}
+
+func (client Client) run(ctx context.Context, wd string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = wd
+	stderr := &bytes.Buffer{}
+	cmd.Stderr = stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("git %s: %w: %s", strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+	return string(out), nil
+}
+
+func (client Client) refExists(ctx context.Context, wd, ref string) bool {
+	_, err := client.run(ctx, wd, "rev-parse", "--verify", "--quiet", ref+"^{commit}")
+	return err == nil
+}
+
+// getBaseRef returns the reference of the base branch.
+// The remote tracking branch is preferred to the local branch.
+// If the base branch isn't specified, the default branch of the remote repository "origin" is used.
+func (client Client) getBaseRef(ctx context.Context, wd, baseBranch string) (string, error) {
+	if baseBranch == "" {
+		if out, err := client.run(ctx, wd, "symbolic-ref", "--quiet", "refs/remotes/origin/HEAD"); err == nil {
+			return strings.TrimSpace(out), nil
+		}
+		for _, b := range []string{"main", "master"} {
+			for _, ref := range []string{"origin/" + b, b} {
+				if client.refExists(ctx, wd, ref) {
+					return ref, nil
+				}
+			}
+		}
+		return "", errors.New("the base branch isn't found. Please specify the base branch")
+	}
+	for _, ref := range []string{"origin/" + baseBranch, baseBranch} {
+		if client.refExists(ctx, wd, ref) {
+			return ref, nil
+		}
+	}
+	return "", errors.New("the base branch isn't found: " + baseBranch)
+}
+
+// ChangedFiles returns files which are changed between HEAD and the merge base of HEAD and the base branch.
+// If params.Staged is true, staged files are returned instead.
+func (client Client) ChangedFiles(ctx context.Context, params ParamsChangedFiles) ([]File, error) {
+	args := []string{"diff", "--name-status", "-z", "--find-renames"}
+	if params.Staged {
+		args = append(args, "--cached")
+	} else {
+		base, err := client.getBaseRef(ctx, params.WorkingDir, params.BaseBranch)
+		if err != nil {
+			return nil, err
+		}
+		out, err := client.run(ctx, params.WorkingDir, "merge-base", base, "HEAD")
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, strings.TrimSpace(out), "HEAD")
+	}
+	out, err := client.run(ctx, params.WorkingDir, args...)
+	if err != nil {
+		return nil, err
+	}
+	return parseNameStatus(out)
+}
+
+var statuses = map[byte]string{ //nolint:gochecknoglobals
+	'A': "added",
+	'M': "modified",
+	'D': "removed",
+	'R': "renamed",
+	'C': "copied",
+	'T': "changed",
+}
+
+// parseNameStatus parses the output of `git diff --name-status -z`.
+func parseNameStatus(out string) ([]File, error) {
+	files := []File{}
+	fields := strings.Split(strings.TrimSuffix(out, "\x00"), "\x00")
+	for i := 0; i < len(fields); i++ {
+		if fields[i] == "" {
+			continue
+		}
+		status, ok := statuses[fields[i][0]]
+		if !ok {
+			status = "changed"
+		}
+		if status == "renamed" || status == "copied" {
+			if i+2 >= len(fields) {
+				return nil, errors.New("failed to parse the output of git diff")
+			}
+			files = append(files, File{
+				Filename:         fields[i+2],
+				PreviousFilename: fields[i+1],
+				Status:           status,
+			})
+			i += 2
+			continue
+		}
+		if i+1 >= len(fields) {
+			return nil, errors.New("failed to parse the output of git diff")
+		}
+		files = append(files, File{
+			Filename: fields[i+1],
+			Status:   status,
+		})
+		i++
+	}
+	return files, nil
+}
@@ -0,0 +1,90 @@
+package git_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/suzuki-shunsuke/buildflow/pkg/git"
+)
+
+func runGit(t *testing.T, wd string, args ...string) {
+	cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+	cmd.Dir = wd
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatal(string(out), err)
+	}
+}
+
+func writeFile(t *testing.T, p, text string) {
+	if err := ioutil.WriteFile(p, []byte(text), 0o644); err != nil { //nolint:gosec
+		t.Fatal(err)
+	}
+}
+
+func TestClient_ChangedFiles(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git isn't installed")
+	}
+	wd, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(wd)
+
+	runGit(t, wd, "init", "-q", "-b", "main")
+	writeFile(t, filepath.Join(wd, "foo.txt"), "foo\n")
+	writeFile(t, filepath.Join(wd, "bar.txt"), "bar\n")
+	writeFile(t, filepath.Join(wd, "zoo.txt"), "zoo\nzoo\nzoo\n")
+	runGit(t, wd, "add", ".")
+	runGit(t, wd, "commit", "-q", "-m", "init")
+	runGit(t, wd, "checkout", "-q", "-b", "feature")
+	writeFile(t, filepath.Join(wd, "foo.txt"), "foo 2\n")
+	runGit(t, wd, "rm", "-q", "bar.txt")
+	runGit(t, wd, "mv", "zoo.txt", "yoo.txt")
+	writeFile(t, filepath.Join(wd, "new.txt"), "new\n")
+	runGit(t, wd, "add", ".")
+	runGit(t, wd, "commit", "-q", "-m", "feature")
+	writeFile(t, filepath.Join(wd, "staged.txt"), "staged\n")
+	runGit(t, wd, "add", "staged.txt")
+
+	ctx := context.Background()
+	client := git.Client{}
+
+	files, err := client.ChangedFiles(ctx, git.ParamsChangedFiles{
+		WorkingDir: wd,
+		BaseBranch: "main",
+	})
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, []git.File{
+		{Filename: "bar.txt", Status: "removed"},
+		{Filename: "foo.txt", Status: "modified"},
+		{Filename: "new.txt", Status: "added"},
+		{Filename: "yoo.txt", Status: "renamed", PreviousFilename: "zoo.txt"},
+	}, files)
+
+	files, err = client.ChangedFiles(ctx, git.ParamsChangedFiles{
+		WorkingDir: wd,
+	})
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Len(t, files, 4)
+
+	files, err = client.ChangedFiles(ctx, git.ParamsChangedFiles{
+		WorkingDir: wd,
+		Staged:     true,
+	})
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, []git.File{
+		{Filename: "staged.txt", Status: "added"},
+	}, files)
+}
@@ -0,0 +1,89 @@
+package httpclient
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/suzuki-shunsuke/buildflow/pkg/domain"
+)
+
+type Client struct {
+	Client *http.Client
+}
+
+func New() Client {
+	return Client{
+		Client: &http.Client{},
+	}
+}
+
+type ParamsSend struct {
+	Method  string
+	URL     string
+	Header  http.Header
+	Query   url.Values
+	Body    string
+	Timeout time.Duration
+}
+
+func (client Client) newRequest(ctx context.Context, params ParamsSend) (*http.Request, error) {
+	u, err := url.Parse(params.URL)
+	if err != nil {
+		return nil, err
+	}
+	if len(params.Query) != 0 {
+		q := u.Query()
+		for k, vals := range params.Query {
+			for _, v := range vals {
+				q.Add(k, v)
+			}
+		}
+		u.RawQuery = q.Encode()
+	}
+	method := params.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), strings.NewReader(params.Body))
+	if err != nil {
+		return nil, err
+	}
+	for k, vals := range params.Header {
+		for _, v := range vals {
+			req.Header.Add(k, v)
+		}
+	}
+	return req, nil
+}
+
+func (client Client) Send(ctx context.Context, params ParamsSend) (domain.HTTPResult, error) {
+	if params.Timeout > 0 {
+		c, cancel := context.WithTimeout(ctx, params.Timeout)
+		defer cancel()
+		ctx = c
+	}
+	req, err := client.newRequest(ctx, params)
+	if err != nil {
+		return domain.HTTPResult{}, err
+	}
+	resp, err := client.Client.Do(req)
+	if err != nil {
+		return domain.HTTPResult{}, err
+	}
+	defer resp.Body.Close()
+	result := domain.HTTPResult{
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+		Header:     resp.Header,
+	}
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return result, err
+	}
+	result.Text = string(b)
+	return result, nil
+}
@@ -0,0 +1,78 @@
+package httpclient_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/suzuki-shunsuke/buildflow/pkg/httpclient"
+)
+
+func TestClient_Send(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		w.Header().Set("X-Method", r.Method)
+		w.Header().Set("X-Query", r.URL.RawQuery)
+		w.Header().Set("X-Token", r.Header.Get("X-Token"))
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write(b)
+	}))
+	defer srv.Close()
+
+	data := []struct {
+		title  string
+		params httpclient.ParamsSend
+		exp    map[string]string
+		body   string
+	}{
+		{
+			title: "default method is GET",
+			params: httpclient.ParamsSend{
+				URL: srv.URL,
+			},
+			exp: map[string]string{
+				"X-Method": "GET",
+			},
+		},
+		{
+			title: "query is merged into the url",
+			params: httpclient.ParamsSend{
+				Method: "POST",
+				URL:    srv.URL + "?foo=bar",
+				Query: url.Values{
+					"zoo": []string{"yoo"},
+				},
+				Header: http.Header{
+					"X-Token": []string{"xxx"},
+				},
+				Body: "hello",
+			},
+			exp: map[string]string{
+				"X-Method": "POST",
+				"X-Query":  "foo=bar&zoo=yoo",
+				"X-Token":  "xxx",
+			},
+			body: "hello",
+		},
+	}
+	ctx := context.Background()
+	client := httpclient.New()
+	for _, d := range data {
+		d := d
+		t.Run(d.title, func(t *testing.T) {
+			result, err := client.Send(ctx, d.params)
+			if !assert.Nil(t, err) {
+				return
+			}
+			assert.Equal(t, http.StatusCreated, result.StatusCode)
+			assert.Equal(t, d.body, result.Text)
+			for k, v := range d.exp {
+				assert.Equal(t, v, result.Header.Get(k))
+			}
+		})
+	}
+}
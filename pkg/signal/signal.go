@@ -1,6 +1,7 @@
 package signal
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"os"
@@ -11,20 +12,91 @@ import (
 
 var once sync.Once //nolint:gochecknoglobals
 
-// Handle calls the function "callback" when the sinal is sent.
+// Handle calls the function "callback" with the signal when the sinal is sent.
 // This is useful to support canceling by signal.
 // Usage:
 //   c, cancel := context.WithCancel(ctx)
-//   go signal.Set(os.Stderr, cancel)
+//   go signal.Handle(os.Stderr, func(os.Signal) { cancel() })
 //   ...
-func Handle(stderr io.Writer, callback func()) {
+func Handle(stderr io.Writer, callback func(os.Signal)) {
 	once.Do(func() {
 		signalChan := make(chan os.Signal, 1)
 		signal.Notify(
 			signalChan, syscall.SIGHUP, syscall.SIGINT,
 			syscall.SIGTERM, syscall.SIGQUIT)
-		sig := <-signalChan
-		fmt.Fprintf(stderr, "send signal %d\n", sig)
-		callback()
+		for sig := range signalChan {
+			fmt.Fprintf(stderr, "send signal %d\n", sig)
+			callback(sig)
+		}
 	})
 }
+
+type ctxKey struct{}
+
+type received struct {
+	mutex sync.RWMutex
+	sig   os.Signal
+	// detached is the context which is cancelled when the signal is sent again
+	detached context.Context
+}
+
+// WithCancel returns the context which is cancelled when the signal is sent.
+// The sent signal can be gotten by Received.
+// The context returned by Detach is cancelled when the signal is sent again.
+func WithCancel(ctx context.Context, stderr io.Writer) (context.Context, context.CancelFunc) {
+	rcv := &received{}
+	detached, cancelDetached := context.WithCancel(context.WithValue(context.Background(), ctxKey{}, rcv))
+	rcv.detached = detached
+	c, cancel := context.WithCancel(context.WithValue(ctx, ctxKey{}, rcv))
+	go Handle(stderr, func(sig os.Signal) {
+		rcv.mutex.Lock()
+		first := rcv.sig == nil
+		if first {
+			rcv.sig = sig
+		}
+		rcv.mutex.Unlock()
+		if first {
+			cancel()
+			return
+		}
+		// the second signal cancels the cleanup
+		cancelDetached()
+	})
+	return c, func() {
+		cancel()
+		cancelDetached()
+	}
+}
+
+// Detach returns the context which isn't cancelled when ctx is cancelled,
+// so that the cleanup can be run after the build is cancelled.
+// If ctx is created by WithCancel, the returned context is cancelled when the signal is sent twice.
+// Otherwise context.Background() is returned.
+func Detach(ctx context.Context) context.Context {
+	rcv, ok := ctx.Value(ctxKey{}).(*received)
+	if !ok {
+		return context.Background()
+	}
+	return rcv.detached
+}
+
+// Received returns the signal which cancelled the context.
+// If the context isn't cancelled by the signal, nil is returned.
+func Received(ctx context.Context) os.Signal {
+	rcv, ok := ctx.Value(ctxKey{}).(*received)
+	if !ok {
+		return nil
+	}
+	rcv.mutex.RLock()
+	defer rcv.mutex.RUnlock()
+	return rcv.sig
+}
+
+// ExitCode returns the conventional exit code of the process which is terminated by the signal.
+// For example, the exit code is 130 if the signal is SIGINT.
+func ExitCode(sig os.Signal) int {
+	if s, ok := sig.(syscall.Signal); ok {
+		return 128 + int(s) //nolint:gomnd
+	}
+	return 1
+}
@@ -0,0 +1,56 @@
+package signal_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/suzuki-shunsuke/buildflow/pkg/signal"
+)
+
+func TestExitCode(t *testing.T) {
+	data := []struct {
+		title string
+		sig   os.Signal
+		exp   int
+	}{
+		{
+			title: "SIGINT",
+			sig:   syscall.SIGINT,
+			exp:   130,
+		},
+		{
+			title: "SIGTERM",
+			sig:   syscall.SIGTERM,
+			exp:   143,
+		},
+	}
+	for _, d := range data {
+		d := d
+		t.Run(d.title, func(t *testing.T) {
+			assert.Equal(t, d.exp, signal.ExitCode(d.sig))
+		})
+	}
+}
+
+func TestReceived(t *testing.T) {
+	// the context which isn't created by WithCancel
+	assert.Nil(t, signal.Received(context.Background()))
+}
+
+func TestDetach(t *testing.T) {
+	// the context which isn't created by WithCancel
+	assert.Nil(t, signal.Detach(context.Background()).Err())
+
+	ctx, cancel := signal.WithCancel(context.Background(), ioutil.Discard)
+	c, cancelChild := context.WithCancel(ctx)
+	cancelChild()
+	// the detached context isn't cancelled by the cancellation of the parent context
+	detached := signal.Detach(c)
+	assert.Nil(t, detached.Err())
+	cancel()
+	assert.NotNil(t, detached.Err())
+}